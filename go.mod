@@ -4,4 +4,13 @@ go 1.24.4
 
 require github.com/google/uuid v1.6.0
 
-require github.com/lib/pq v1.10.9
+require (
+	github.com/andybalholm/brotli v1.1.1
+	github.com/lib/pq v1.10.9
+	golang.org/x/crypto v0.41.0
+	golang.org/x/net v0.43.0
+	golang.org/x/term v0.34.0
+	golang.org/x/text v0.28.0
+)
+
+require golang.org/x/sys v0.35.0 // indirect
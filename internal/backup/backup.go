@@ -0,0 +1,437 @@
+package backup
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/UUest/gator/internal/database"
+)
+
+// Format identifies gator archives, and Version is bumped whenever a record
+// type changes incompatibly.
+const (
+	Format  = "gator-backup"
+	Version = 1
+)
+
+// postPageSize is how many posts are read from the database at a time.
+const postPageSize = 500
+
+// Record types, in the order they are written. Later records refer to earlier
+// ones, so an archive can be restored in a single pass.
+const (
+	TypeHeader          = "header"
+	TypeUser            = "user"
+	TypeFeed            = "feed"
+	TypeFeedFollow      = "feed_follow"
+	TypePost            = "post"
+	TypePostState       = "post_state"
+	TypeEnclosure       = "enclosure"
+	TypePodcastDownload = "podcast_download"
+	TypeFilterRule      = "filter_rule"
+	TypeSavedSearch     = "saved_search"
+	TypeSearchMatch     = "search_match"
+	TypeWebhook         = "webhook"
+	TypePrunedPost      = "pruned_post"
+)
+
+// Line is one line of an archive: a record type and its JSON payload.
+type Line struct {
+	Type   string          `json:"type"`
+	Record json.RawMessage `json:"record"`
+}
+
+type Header struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type User struct {
+	ID           uuid.UUID `json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	Name         string    `json:"name"`
+	PasswordHash string    `json:"password_hash"`
+	Role         string    `json:"role"`
+}
+
+type Feed struct {
+	ID          uuid.UUID  `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Name        string     `json:"name"`
+	URL         string     `json:"url"`
+	UserID      uuid.UUID  `json:"user_id"`
+	LastFetched *time.Time `json:"last_fetched,omitempty"`
+	Readability bool       `json:"readability"`
+	RetiredAt   *time.Time `json:"retired_at,omitempty"`
+	// RetentionPosts and RetentionDays are unset when the feed follows the
+	// global retention policy.
+	RetentionPosts *int32 `json:"retention_posts,omitempty"`
+	RetentionDays  *int32 `json:"retention_days,omitempty"`
+}
+
+type FeedFollow struct {
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UserID    uuid.UUID `json:"user_id"`
+	FeedID    uuid.UUID `json:"feed_id"`
+}
+
+// Post and the records below identify posts by URL, which is unique, because
+// post IDs are assigned by the database and differ between instances.
+type Post struct {
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Title       string    `json:"title"`
+	URL         string    `json:"url"`
+	Description string    `json:"description"`
+	PublishedAt time.Time `json:"published_at"`
+	FeedID      uuid.UUID `json:"feed_id"`
+	Content     string    `json:"content,omitempty"`
+	Author      string    `json:"author,omitempty"`
+	Categories  []string  `json:"categories,omitempty"`
+	CommentsURL string    `json:"comments_url,omitempty"`
+}
+
+type PostState struct {
+	UserID    uuid.UUID  `json:"user_id"`
+	PostURL   string     `json:"post_url"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+	Starred   bool       `json:"starred"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+type Enclosure struct {
+	PostURL  string `json:"post_url"`
+	URL      string `json:"url"`
+	MimeType string `json:"mime_type"`
+	Length   int64  `json:"length"`
+}
+
+type PodcastDownload struct {
+	UserID       uuid.UUID  `json:"user_id"`
+	PostURL      string     `json:"post_url"`
+	EnclosureURL string     `json:"enclosure_url"`
+	Path         string     `json:"path,omitempty"`
+	Sha256       string     `json:"sha256,omitempty"`
+	Size         int64      `json:"size,omitempty"`
+	DownloadedAt *time.Time `json:"downloaded_at,omitempty"`
+	PlayedAt     *time.Time `json:"played_at,omitempty"`
+}
+
+// FilterRule applies to every feed its user follows when FeedID is unset.
+type FilterRule struct {
+	CreatedAt    time.Time  `json:"created_at"`
+	UserID       uuid.UUID  `json:"user_id"`
+	FeedID       *uuid.UUID `json:"feed_id,omitempty"`
+	TitlePattern string     `json:"title_pattern"`
+	Action       string     `json:"action"`
+}
+
+type SavedSearch struct {
+	CreatedAt time.Time `json:"created_at"`
+	UserID    uuid.UUID `json:"user_id"`
+	Name      string    `json:"name"`
+	Query     string    `json:"query"`
+}
+
+// SearchMatch is a post in a saved search's inbox, which the search's user
+// has seen once SeenAt is set.
+type SearchMatch struct {
+	UserID     uuid.UUID  `json:"user_id"`
+	SearchName string     `json:"search_name"`
+	PostURL    string     `json:"post_url"`
+	MatchedAt  time.Time  `json:"matched_at"`
+	SeenAt     *time.Time `json:"seen_at,omitempty"`
+}
+
+// Webhook receives every new post from the feeds its user follows when FeedID
+// is unset. Its delivery log is not backed up.
+type Webhook struct {
+	CreatedAt time.Time  `json:"created_at"`
+	UserID    uuid.UUID  `json:"user_id"`
+	FeedID    *uuid.UUID `json:"feed_id,omitempty"`
+	URL       string     `json:"url"`
+	Secret    string     `json:"secret"`
+}
+
+// PrunedPost is the URL of a post the retention policy deleted, kept so agg
+// doesn't store the post again while it is still in its feed.
+type PrunedPost struct {
+	URL      string    `json:"url"`
+	FeedID   uuid.UUID `json:"feed_id"`
+	PrunedAt time.Time `json:"pruned_at"`
+}
+
+// Write dumps the whole database to w as a gzip-compressed JSON-lines
+// archive. Login sessions are deliberately left out. db should be bound to a
+// repeatable read transaction, as the archive is read with many queries.
+func Write(ctx context.Context, db *database.Queries, w io.Writer) error {
+	zw := gzip.NewWriter(w)
+	enc := json.NewEncoder(zw)
+	emit := func(recordType string, record any) error {
+		raw, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		return enc.Encode(Line{Type: recordType, Record: raw})
+	}
+
+	if err := emit(TypeHeader, Header{Format: Format, Version: Version, CreatedAt: time.Now().UTC()}); err != nil {
+		return err
+	}
+
+	users, err := db.GetUsers(ctx)
+	if err != nil {
+		return err
+	}
+	for _, u := range users {
+		err := emit(TypeUser, User{
+			ID:           u.ID,
+			CreatedAt:    u.CreatedAt,
+			UpdatedAt:    u.UpdatedAt,
+			Name:         u.Name,
+			PasswordHash: u.PasswordHash,
+			Role:         u.Role,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	feeds, err := db.GetFeeds(ctx)
+	if err != nil {
+		return err
+	}
+	for _, f := range feeds {
+		err := emit(TypeFeed, Feed{
+			ID:             f.ID,
+			CreatedAt:      f.CreatedAt,
+			UpdatedAt:      f.UpdatedAt,
+			Name:           f.Name,
+			URL:            f.Url,
+			UserID:         f.UserID,
+			LastFetched:    nullTime(f.LastFetched),
+			Readability:    f.Readability,
+			RetiredAt:      nullTime(f.RetiredAt),
+			RetentionPosts: nullInt32(f.RetentionPosts),
+			RetentionDays:  nullInt32(f.RetentionDays),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	follows, err := db.ListFeedFollows(ctx)
+	if err != nil {
+		return err
+	}
+	for _, ff := range follows {
+		err := emit(TypeFeedFollow, FeedFollow{
+			CreatedAt: ff.CreatedAt,
+			UpdatedAt: ff.UpdatedAt,
+			UserID:    ff.UserID,
+			FeedID:    ff.FeedID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	var lastID int32
+	for {
+		posts, err := db.ListPostsAfter(ctx, database.ListPostsAfterParams{ID: lastID, Limit: postPageSize})
+		if err != nil {
+			return err
+		}
+		for _, p := range posts {
+			err := emit(TypePost, Post{
+				CreatedAt:   p.CreatedAt,
+				UpdatedAt:   p.UpdatedAt,
+				Title:       p.Title,
+				URL:         p.Url,
+				Description: p.Description,
+				PublishedAt: p.PublishedAt,
+				FeedID:      p.FeedID,
+				Content:     p.Content,
+				Author:      p.Author,
+				Categories:  p.Categories,
+				CommentsURL: p.CommentsUrl,
+			})
+			if err != nil {
+				return err
+			}
+			lastID = p.ID
+		}
+		if len(posts) < postPageSize {
+			break
+		}
+	}
+
+	states, err := db.ListPostStates(ctx)
+	if err != nil {
+		return err
+	}
+	for _, ps := range states {
+		err := emit(TypePostState, PostState{
+			UserID:    ps.UserID,
+			PostURL:   ps.PostUrl,
+			ReadAt:    nullTime(ps.ReadAt),
+			Starred:   ps.Starred,
+			UpdatedAt: ps.UpdatedAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	enclosures, err := db.ListEnclosures(ctx)
+	if err != nil {
+		return err
+	}
+	for _, e := range enclosures {
+		err := emit(TypeEnclosure, Enclosure{
+			PostURL:  e.PostUrl,
+			URL:      e.Url,
+			MimeType: e.MimeType,
+			Length:   e.Length,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	downloads, err := db.ListPodcastDownloads(ctx)
+	if err != nil {
+		return err
+	}
+	for _, pd := range downloads {
+		err := emit(TypePodcastDownload, PodcastDownload{
+			UserID:       pd.UserID,
+			PostURL:      pd.PostUrl,
+			EnclosureURL: pd.EnclosureUrl,
+			Path:         pd.Path,
+			Sha256:       pd.Sha256,
+			Size:         pd.Size,
+			DownloadedAt: nullTime(pd.DownloadedAt),
+			PlayedAt:     nullTime(pd.PlayedAt),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	rules, err := db.ListFilterRules(ctx)
+	if err != nil {
+		return err
+	}
+	for _, fr := range rules {
+		var feedID *uuid.UUID
+		if fr.FeedID.Valid {
+			feedID = &fr.FeedID.UUID
+		}
+		err := emit(TypeFilterRule, FilterRule{
+			CreatedAt:    fr.CreatedAt,
+			UserID:       fr.UserID,
+			FeedID:       feedID,
+			TitlePattern: fr.TitlePattern,
+			Action:       fr.Action,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	searches, err := db.ListSavedSearches(ctx)
+	if err != nil {
+		return err
+	}
+	for _, ss := range searches {
+		err := emit(TypeSavedSearch, SavedSearch{
+			CreatedAt: ss.CreatedAt,
+			UserID:    ss.UserID,
+			Name:      ss.Name,
+			Query:     ss.Query,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	matches, err := db.ListSearchMatches(ctx)
+	if err != nil {
+		return err
+	}
+	for _, sm := range matches {
+		err := emit(TypeSearchMatch, SearchMatch{
+			UserID:     sm.UserID,
+			SearchName: sm.SearchName,
+			PostURL:    sm.PostUrl,
+			MatchedAt:  sm.MatchedAt,
+			SeenAt:     nullTime(sm.SeenAt),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	hooks, err := db.ListWebhooks(ctx)
+	if err != nil {
+		return err
+	}
+	for _, wh := range hooks {
+		var feedID *uuid.UUID
+		if wh.FeedID.Valid {
+			feedID = &wh.FeedID.UUID
+		}
+		err := emit(TypeWebhook, Webhook{
+			CreatedAt: wh.CreatedAt,
+			UserID:    wh.UserID,
+			FeedID:    feedID,
+			URL:       wh.Url,
+			Secret:    wh.Secret,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	pruned, err := db.ListPrunedPosts(ctx)
+	if err != nil {
+		return err
+	}
+	for _, pp := range pruned {
+		err := emit(TypePrunedPost, PrunedPost{
+			URL:      pp.Url,
+			FeedID:   pp.FeedID,
+			PrunedAt: pp.PrunedAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func nullInt32(n sql.NullInt32) *int32 {
+	if !n.Valid {
+		return nil
+	}
+	return &n.Int32
+}
@@ -0,0 +1,395 @@
+package backup
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/UUest/gator/internal/database"
+)
+
+// maxLineSize bounds a single archive line; posts with extracted article
+// content can be large.
+const maxLineSize = 64 << 20
+
+// Count tracks how many records of one type were read from an archive and how
+// many of them were new to the database.
+type Count struct {
+	Read  int
+	Added int
+}
+
+// Stats maps record types to their counts.
+type Stats map[string]*Count
+
+func (st Stats) add(recordType string, added int64) {
+	c, ok := st[recordType]
+	if !ok {
+		c = &Count{}
+		st[recordType] = c
+	}
+	c.Read++
+	c.Added += int(added)
+}
+
+// Restore loads an archive written by Write into db. Records that already
+// exist are left untouched, so restoring the same archive twice, or into a
+// database that already has data, is safe. Users are matched by ID and then
+// by name, feeds by ID and then by URL, and posts by URL.
+func Restore(ctx context.Context, db *database.Queries, r io.Reader) (Stats, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	rs := &restorer{
+		db:    db,
+		users: map[uuid.UUID]uuid.UUID{},
+		feeds: map[uuid.UUID]uuid.UUID{},
+		stats: Stats{},
+	}
+	scanner := bufio.NewScanner(zr)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		var line Line
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		if lineNo == 1 {
+			if err := checkHeader(line); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err := rs.restore(ctx, line); err != nil {
+			return nil, fmt.Errorf("line %d (%s): %w", lineNo, line.Type, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if lineNo == 0 {
+		return nil, fmt.Errorf("archive is empty")
+	}
+	return rs.stats, nil
+}
+
+func checkHeader(line Line) error {
+	if line.Type != TypeHeader {
+		return fmt.Errorf("not a gator archive: missing header")
+	}
+	var h Header
+	if err := json.Unmarshal(line.Record, &h); err != nil {
+		return err
+	}
+	if h.Format != Format {
+		return fmt.Errorf("not a gator archive: format %q", h.Format)
+	}
+	if h.Version > Version {
+		return fmt.Errorf("archive version %d is newer than supported version %d", h.Version, Version)
+	}
+	return nil
+}
+
+type restorer struct {
+	db *database.Queries
+	// users and feeds map IDs in the archive to the IDs of the matching rows
+	// in the database, which differ when merging into existing data.
+	users map[uuid.UUID]uuid.UUID
+	feeds map[uuid.UUID]uuid.UUID
+	stats Stats
+}
+
+func (rs *restorer) restore(ctx context.Context, line Line) error {
+	switch line.Type {
+	case TypeUser:
+		var rec User
+		if err := json.Unmarshal(line.Record, &rec); err != nil {
+			return err
+		}
+		return rs.restoreUser(ctx, rec)
+	case TypeFeed:
+		var rec Feed
+		if err := json.Unmarshal(line.Record, &rec); err != nil {
+			return err
+		}
+		return rs.restoreFeed(ctx, rec)
+	case TypeFeedFollow:
+		var rec FeedFollow
+		if err := json.Unmarshal(line.Record, &rec); err != nil {
+			return err
+		}
+		added, err := rs.db.RestoreFeedFollow(ctx, database.RestoreFeedFollowParams{
+			CreatedAt: rec.CreatedAt,
+			UpdatedAt: rec.UpdatedAt,
+			UserID:    rs.userID(rec.UserID),
+			FeedID:    rs.feedID(rec.FeedID),
+		})
+		rs.stats.add(line.Type, added)
+		return err
+	case TypePost:
+		var rec Post
+		if err := json.Unmarshal(line.Record, &rec); err != nil {
+			return err
+		}
+		added, err := rs.db.RestorePost(ctx, database.RestorePostParams{
+			CreatedAt:   rec.CreatedAt,
+			UpdatedAt:   rec.UpdatedAt,
+			Title:       rec.Title,
+			Url:         rec.URL,
+			Description: rec.Description,
+			PublishedAt: rec.PublishedAt,
+			FeedID:      rs.feedID(rec.FeedID),
+			Content:     rec.Content,
+			Author:      rec.Author,
+			Categories:  nonNil(rec.Categories),
+			CommentsUrl: rec.CommentsURL,
+		})
+		rs.stats.add(line.Type, added)
+		return err
+	case TypePostState:
+		var rec PostState
+		if err := json.Unmarshal(line.Record, &rec); err != nil {
+			return err
+		}
+		postID, err := rs.db.GetPostIDByURL(ctx, rec.PostURL)
+		if err != nil {
+			return err
+		}
+		added, err := rs.db.RestorePostState(ctx, database.RestorePostStateParams{
+			UserID:    rs.userID(rec.UserID),
+			PostID:    postID,
+			ReadAt:    toNullTime(rec.ReadAt),
+			Starred:   rec.Starred,
+			UpdatedAt: rec.UpdatedAt,
+		})
+		rs.stats.add(line.Type, added)
+		return err
+	case TypeEnclosure:
+		var rec Enclosure
+		if err := json.Unmarshal(line.Record, &rec); err != nil {
+			return err
+		}
+		postID, err := rs.db.GetPostIDByURL(ctx, rec.PostURL)
+		if err != nil {
+			return err
+		}
+		added, err := rs.db.RestoreEnclosure(ctx, database.RestoreEnclosureParams{
+			PostID:   postID,
+			Url:      rec.URL,
+			MimeType: rec.MimeType,
+			Length:   rec.Length,
+		})
+		rs.stats.add(line.Type, added)
+		return err
+	case TypePodcastDownload:
+		var rec PodcastDownload
+		if err := json.Unmarshal(line.Record, &rec); err != nil {
+			return err
+		}
+		postID, err := rs.db.GetPostIDByURL(ctx, rec.PostURL)
+		if err != nil {
+			return err
+		}
+		enclosureID, err := rs.db.GetEnclosureID(ctx, database.GetEnclosureIDParams{
+			PostID: postID,
+			Url:    rec.EnclosureURL,
+		})
+		if err != nil {
+			return err
+		}
+		added, err := rs.db.RestorePodcastDownload(ctx, database.RestorePodcastDownloadParams{
+			UserID:       rs.userID(rec.UserID),
+			EnclosureID:  enclosureID,
+			Path:         rec.Path,
+			Sha256:       rec.Sha256,
+			Size:         rec.Size,
+			DownloadedAt: toNullTime(rec.DownloadedAt),
+			PlayedAt:     toNullTime(rec.PlayedAt),
+		})
+		rs.stats.add(line.Type, added)
+		return err
+	case TypeFilterRule:
+		var rec FilterRule
+		if err := json.Unmarshal(line.Record, &rec); err != nil {
+			return err
+		}
+		var feedID uuid.NullUUID
+		if rec.FeedID != nil {
+			feedID = uuid.NullUUID{UUID: rs.feedID(*rec.FeedID), Valid: true}
+		}
+		added, err := rs.db.RestoreFilterRule(ctx, database.RestoreFilterRuleParams{
+			CreatedAt:    rec.CreatedAt,
+			UserID:       rs.userID(rec.UserID),
+			FeedID:       feedID,
+			TitlePattern: rec.TitlePattern,
+			Action:       rec.Action,
+		})
+		rs.stats.add(line.Type, added)
+		return err
+	case TypeSavedSearch:
+		var rec SavedSearch
+		if err := json.Unmarshal(line.Record, &rec); err != nil {
+			return err
+		}
+		added, err := rs.db.RestoreSavedSearch(ctx, database.RestoreSavedSearchParams{
+			CreatedAt: rec.CreatedAt,
+			UserID:    rs.userID(rec.UserID),
+			Name:      rec.Name,
+			Query:     rec.Query,
+		})
+		rs.stats.add(line.Type, added)
+		return err
+	case TypeSearchMatch:
+		var rec SearchMatch
+		if err := json.Unmarshal(line.Record, &rec); err != nil {
+			return err
+		}
+		postID, err := rs.db.GetPostIDByURL(ctx, rec.PostURL)
+		if err != nil {
+			return err
+		}
+		added, err := rs.db.RestoreSearchMatch(ctx, database.RestoreSearchMatchParams{
+			PostID:     postID,
+			MatchedAt:  rec.MatchedAt,
+			SeenAt:     toNullTime(rec.SeenAt),
+			UserID:     rs.userID(rec.UserID),
+			SearchName: rec.SearchName,
+		})
+		rs.stats.add(line.Type, added)
+		return err
+	case TypeWebhook:
+		var rec Webhook
+		if err := json.Unmarshal(line.Record, &rec); err != nil {
+			return err
+		}
+		var feedID uuid.NullUUID
+		if rec.FeedID != nil {
+			feedID = uuid.NullUUID{UUID: rs.feedID(*rec.FeedID), Valid: true}
+		}
+		added, err := rs.db.RestoreWebhook(ctx, database.RestoreWebhookParams{
+			CreatedAt: rec.CreatedAt,
+			UserID:    rs.userID(rec.UserID),
+			FeedID:    feedID,
+			Url:       rec.URL,
+			Secret:    rec.Secret,
+		})
+		rs.stats.add(line.Type, added)
+		return err
+	case TypePrunedPost:
+		var rec PrunedPost
+		if err := json.Unmarshal(line.Record, &rec); err != nil {
+			return err
+		}
+		added, err := rs.db.RestorePrunedPost(ctx, database.RestorePrunedPostParams{
+			Url:      rec.URL,
+			FeedID:   rs.feedID(rec.FeedID),
+			PrunedAt: rec.PrunedAt,
+		})
+		rs.stats.add(line.Type, added)
+		return err
+	default:
+		// Records from newer minor additions are skipped rather than failing
+		// the whole restore.
+		return nil
+	}
+}
+
+func (rs *restorer) restoreUser(ctx context.Context, rec User) error {
+	if existing, err := rs.db.GetUserById(ctx, rec.ID); err == nil {
+		rs.users[rec.ID] = existing.ID
+		rs.stats.add(TypeUser, 0)
+		return nil
+	} else if err != sql.ErrNoRows {
+		return err
+	}
+	if existing, err := rs.db.GetUser(ctx, rec.Name); err == nil {
+		rs.users[rec.ID] = existing.ID
+		rs.stats.add(TypeUser, 0)
+		return nil
+	} else if err != sql.ErrNoRows {
+		return err
+	}
+	added, err := rs.db.RestoreUser(ctx, database.RestoreUserParams{
+		ID:           rec.ID,
+		CreatedAt:    rec.CreatedAt,
+		UpdatedAt:    rec.UpdatedAt,
+		Name:         rec.Name,
+		PasswordHash: rec.PasswordHash,
+		Role:         rec.Role,
+	})
+	rs.users[rec.ID] = rec.ID
+	rs.stats.add(TypeUser, added)
+	return err
+}
+
+func (rs *restorer) restoreFeed(ctx context.Context, rec Feed) error {
+	if existing, err := rs.db.GetFeedByURL(ctx, rec.URL); err == nil {
+		rs.feeds[rec.ID] = existing.ID
+		rs.stats.add(TypeFeed, 0)
+		return nil
+	} else if err != sql.ErrNoRows {
+		return err
+	}
+	added, err := rs.db.RestoreFeed(ctx, database.RestoreFeedParams{
+		ID:             rec.ID,
+		CreatedAt:      rec.CreatedAt,
+		UpdatedAt:      rec.UpdatedAt,
+		Name:           rec.Name,
+		Url:            rec.URL,
+		UserID:         rs.userID(rec.UserID),
+		LastFetched:    toNullTime(rec.LastFetched),
+		Readability:    rec.Readability,
+		RetiredAt:      toNullTime(rec.RetiredAt),
+		RetentionPosts: toNullInt32(rec.RetentionPosts),
+		RetentionDays:  toNullInt32(rec.RetentionDays),
+	})
+	rs.feeds[rec.ID] = rec.ID
+	rs.stats.add(TypeFeed, added)
+	return err
+}
+
+func (rs *restorer) userID(id uuid.UUID) uuid.UUID {
+	if mapped, ok := rs.users[id]; ok {
+		return mapped
+	}
+	return id
+}
+
+func (rs *restorer) feedID(id uuid.UUID) uuid.UUID {
+	if mapped, ok := rs.feeds[id]; ok {
+		return mapped
+	}
+	return id
+}
+
+func toNullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *t, Valid: true}
+}
+
+func toNullInt32(n *int32) sql.NullInt32 {
+	if n == nil {
+		return sql.NullInt32{}
+	}
+	return sql.NullInt32{Int32: *n, Valid: true}
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
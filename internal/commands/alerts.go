@@ -0,0 +1,321 @@
+package commands
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode"
+
+	"github.com/UUest/gator/internal/database"
+	"github.com/UUest/gator/internal/render"
+)
+
+// searchTerm is a word or quoted phrase from a saved search query. A post
+// matches the query when it contains every term and none of the excluded ones.
+type searchTerm struct {
+	text    string
+	exclude bool
+}
+
+// savedSearch is a stored search with its query parsed.
+type savedSearch struct {
+	database.SavedSearch
+	terms []searchTerm
+}
+
+// parseSearchQuery splits a query into lower-cased terms. Terms are separated
+// by spaces, "double quotes" group a phrase and a leading - excludes a term.
+func parseSearchQuery(query string) ([]searchTerm, error) {
+	var terms []searchTerm
+	included := false
+	rest := strings.TrimSpace(query)
+	for rest != "" {
+		var term searchTerm
+		if strings.HasPrefix(rest, "-") {
+			term.exclude = true
+			rest = rest[1:]
+		}
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quote")
+			}
+			term.text = rest[1 : end+1]
+			rest = rest[end+2:]
+		} else {
+			end := strings.IndexFunc(rest, unicode.IsSpace)
+			if end < 0 {
+				end = len(rest)
+			}
+			term.text = rest[:end]
+			rest = rest[end:]
+		}
+		rest = strings.TrimLeftFunc(rest, unicode.IsSpace)
+		term.text = normalizeSearchText(term.text)
+		if term.text == "" {
+			continue
+		}
+		terms = append(terms, term)
+		included = included || !term.exclude
+	}
+	if !included {
+		return nil, fmt.Errorf("nothing to search for")
+	}
+	return terms, nil
+}
+
+// normalizeSearchText lower-cases s and collapses its whitespace, so phrases
+// match across line breaks.
+func normalizeSearchText(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// postSearchText is the text of a post that saved searches are matched
+// against: its title and the plain text of its description and content.
+func postSearchText(title, description, content string) string {
+	return normalizeSearchText(title + "\n" + render.Text(description) + "\n" + render.Text(content))
+}
+
+func matchesSearch(terms []searchTerm, text string) bool {
+	for _, term := range terms {
+		if strings.Contains(text, term.text) == term.exclude {
+			return false
+		}
+	}
+	return true
+}
+
+// compileSavedSearches parses each search's query, logging and leaving out
+// any that no longer parses.
+func compileSavedSearches(log *slog.Logger, searches []database.SavedSearch) []savedSearch {
+	compiled := make([]savedSearch, 0, len(searches))
+	for _, search := range searches {
+		terms, err := parseSearchQuery(search.Query)
+		if err != nil {
+			log.Warn("skipping saved search with an invalid query", "search_id", search.ID, "search", search.Name, "err", err)
+			continue
+		}
+		compiled = append(compiled, savedSearch{SavedSearch: search, terms: terms})
+	}
+	return compiled
+}
+
+// applySavedSearches adds a post agg has just stored to the inbox of every
+// search it matches. searches may belong to any of the feed's followers.
+func applySavedSearches(ctx context.Context, s *State, log *slog.Logger, searches []savedSearch, postID int32, title, description, content string) error {
+	if len(searches) == 0 {
+		return nil
+	}
+	text := postSearchText(title, description, content)
+	for _, search := range searches {
+		if !matchesSearch(search.terms, text) {
+			continue
+		}
+		err := s.DB.CreateSearchMatch(ctx, database.CreateSearchMatchParams{
+			SearchID: search.ID,
+			PostID:   postID,
+		})
+		if err != nil {
+			return err
+		}
+		log.Debug("saved search matched", "search_id", search.ID, "search", search.Name, "post_id", postID)
+	}
+	return nil
+}
+
+// HandlerAlerts lists the posts the current user's saved searches have
+// matched since they last looked, or dispatches the subcommands that manage
+// the searches themselves.
+func HandlerAlerts(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) > 0 {
+		sub := Command{Name: cmd.Args[0], Args: cmd.Args[1:]}
+		switch sub.Name {
+		case "add":
+			return handlerAlertsAdd(s, sub, user)
+		case "searches":
+			return handlerAlertsSearches(s, sub, user)
+		case "remove":
+			return handlerAlertsRemove(s, sub, user)
+		case "test":
+			return handlerAlertsTest(s, sub, user)
+		}
+	}
+
+	flags := flag.NewFlagSet("alerts", flag.ContinueOnError)
+	searchName := flags.String("search", "", "only list matches of this saved search")
+	keep := flags.Bool("keep", false, "leave the matches in the inbox instead of marking them seen")
+	if err := flags.Parse(cmd.Args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return fmt.Errorf("Usage: gator alerts [--keep] [--search <name>] | gator alerts add|searches|remove|test [args...]")
+	}
+	name := sql.NullString{String: *searchName, Valid: *searchName != ""}
+
+	var matches []database.GetUnseenSearchMatchesRow
+	if *keep {
+		var err error
+		matches, err = s.DB.GetUnseenSearchMatches(s.Ctx, database.GetUnseenSearchMatchesParams{
+			UserID:     user.ID,
+			SearchName: name,
+		})
+		if err != nil {
+			return err
+		}
+	} else {
+		taken, err := s.DB.TakeUnseenSearchMatches(s.Ctx, database.TakeUnseenSearchMatchesParams{
+			UserID:     user.ID,
+			SearchName: name,
+		})
+		if err != nil {
+			return err
+		}
+		for _, match := range taken {
+			matches = append(matches, database.GetUnseenSearchMatchesRow(match))
+		}
+	}
+
+	if len(matches) == 0 {
+		if name.Valid {
+			if _, err := userSavedSearch(s, user, name.String); err != nil {
+				return err
+			}
+		}
+		fmt.Println("No new alerts")
+		return nil
+	}
+	for i := 0; i < len(matches); {
+		end := i
+		for end < len(matches) && matches[end].SearchName == matches[i].SearchName {
+			end++
+		}
+		fmt.Printf("%s (%d new):\n", matches[i].SearchName, end-i)
+		for _, match := range matches[i:end] {
+			fmt.Printf("  %d: %s [%s]\n", match.PostID, render.StripControl(match.Title), render.StripControl(match.FeedName))
+			fmt.Printf("      %s\n", render.StripControl(match.Url))
+		}
+		i = end
+	}
+	return nil
+}
+
+func handlerAlertsAdd(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) < 2 {
+		return fmt.Errorf(`Usage: gator alerts add <name> <query>, e.g. gator alerts add acme 'acme "widget pro" -hiring'`)
+	}
+	name := cmd.Args[0]
+	query := strings.Join(cmd.Args[1:], " ")
+	if _, err := parseSearchQuery(query); err != nil {
+		return fmt.Errorf("Invalid search query: %w", err)
+	}
+	search, err := s.DB.CreateSavedSearch(s.Ctx, database.CreateSavedSearchParams{
+		UserID: user.ID,
+		Name:   name,
+		Query:  query,
+	})
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("You already have a saved search named %s", name)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Added saved search %s: %s\n", search.Name, search.Query)
+	fmt.Println("New posts that match it will show up in gator alerts")
+	return nil
+}
+
+func handlerAlertsSearches(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) != 0 {
+		return fmt.Errorf("Usage: gator alerts searches")
+	}
+	searches, err := s.DB.GetSavedSearchesForUser(s.Ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if len(searches) == 0 {
+		fmt.Println("No saved searches")
+		return nil
+	}
+	fmt.Println("Saved searches:")
+	for _, search := range searches {
+		fmt.Printf("%s: %s (%d new, %d total)\n", search.Name, search.Query, search.Unseen, search.Matches)
+	}
+	return nil
+}
+
+func handlerAlertsRemove(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("Usage: gator alerts remove <name>")
+	}
+	removed, err := s.DB.DeleteSavedSearch(s.Ctx, database.DeleteSavedSearchParams{
+		UserID: user.ID,
+		Name:   cmd.Args[0],
+	})
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		return fmt.Errorf("Saved search %s not found", cmd.Args[0])
+	}
+	fmt.Printf("Saved search %s removed\n", cmd.Args[0])
+	return nil
+}
+
+// handlerAlertsTest lists which of the user's recent posts a query matches,
+// either a saved search given by name or a query that hasn't been saved yet.
+func handlerAlertsTest(s *State, cmd Command, user database.User) error {
+	flags := flag.NewFlagSet("alerts test", flag.ContinueOnError)
+	query := flags.String("query", "", "query to test instead of a saved search")
+	limit := flags.Int("limit", 100, "how many recent posts to test against")
+	if err := flags.Parse(cmd.Args); err != nil {
+		return err
+	}
+	if (*query == "") == (flags.NArg() == 0) || flags.NArg() > 1 || *limit <= 0 {
+		return fmt.Errorf("Usage: gator alerts test [--limit <n>] <name> | gator alerts test [--limit <n>] --query <query>")
+	}
+	if *query == "" {
+		search, err := userSavedSearch(s, user, flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		*query = search.Query
+	}
+	terms, err := parseSearchQuery(*query)
+	if err != nil {
+		return fmt.Errorf("Invalid search query: %w", err)
+	}
+
+	posts, err := s.DB.GetPostsForUser(s.Ctx, database.GetPostsForUserParams{
+		UserID: user.ID,
+		Limit:  int32(*limit),
+	})
+	if err != nil {
+		return err
+	}
+	matched := 0
+	for _, post := range posts {
+		if matchesSearch(terms, postSearchText(post.Title, post.Description, post.Content)) {
+			matched++
+			fmt.Printf("%d: %s\n", post.ID, render.StripControl(post.Title))
+		}
+	}
+	fmt.Printf("Matched %d of %d recent posts\n", matched, len(posts))
+	return nil
+}
+
+// userSavedSearch looks up one of user's saved searches by name.
+func userSavedSearch(s *State, user database.User, name string) (database.GetSavedSearchesForUserRow, error) {
+	searches, err := s.DB.GetSavedSearchesForUser(s.Ctx, user.ID)
+	if err != nil {
+		return database.GetSavedSearchesForUserRow{}, err
+	}
+	for _, search := range searches {
+		if search.Name == name {
+			return search, nil
+		}
+	}
+	return database.GetSavedSearchesForUserRow{}, fmt.Errorf("Saved search %s not found", name)
+}
@@ -0,0 +1,232 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/term"
+
+	"github.com/UUest/gator/internal/database"
+)
+
+const (
+	minPasswordLength = 8
+	sessionLifetime   = 30 * 24 * time.Hour
+)
+
+// dummyPasswordHash is compared against when a login names a user that
+// doesn't exist or has no password, so that it takes as long as a real one.
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, err := bcrypt.GenerateFromPassword([]byte("not a password"), bcrypt.DefaultCost)
+	if err != nil {
+		panic(err)
+	}
+	return hash
+})
+
+// stdinReader is shared by every prompt so that piped input is not lost to
+// the buffering of an earlier read.
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// readPassword prompts for a password without echoing it. When stdin is not a
+// terminal, a line is read from it instead so that scripts can pipe one in.
+func readPassword(ctx context.Context, prompt string) (string, error) {
+	fmt.Print(prompt)
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		// ReadPassword turns echo off until it returns, which it won't if
+		// the prompt is abandoned, so the terminal is restored here instead.
+		state, err := term.GetState(fd)
+		if err != nil {
+			return "", err
+		}
+		password, err := readInput(ctx, func() (string, error) {
+			password, err := term.ReadPassword(fd)
+			return string(password), err
+		})
+		if ctx.Err() != nil {
+			term.Restore(fd, state)
+		}
+		fmt.Println()
+		return password, err
+	}
+	line, err := readInput(ctx, func() (string, error) {
+		return stdinReader.ReadString('\n')
+	})
+	if err != nil && line == "" {
+		return "", err
+	}
+	fmt.Println()
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readInput waits for read to finish unless ctx is cancelled first, so that
+// Ctrl-C at a prompt stops gator rather than leaving it blocked on stdin.
+func readInput(ctx context.Context, read func() (string, error)) (string, error) {
+	type result struct {
+		line string
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		line, err := read()
+		done <- result{line, err}
+	}()
+	select {
+	case r := <-done:
+		return r.line, r.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// readNewPassword prompts for a new password twice and returns its hash.
+func readNewPassword(ctx context.Context) (string, error) {
+	password, err := readPassword(ctx, "New password: ")
+	if err != nil {
+		return "", err
+	}
+	if len(password) < minPasswordLength {
+		return "", fmt.Errorf("Password must be at least %d characters", minPasswordLength)
+	}
+	confirm, err := readPassword(ctx, "Confirm password: ")
+	if err != nil {
+		return "", err
+	}
+	if password != confirm {
+		return "", fmt.Errorf("Passwords do not match")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// startSession issues a new session token for user and stores it in the
+// config. Only a hash of the token is kept in the database. The session the
+// config held before is ended, and expired ones are cleared out on the way.
+func startSession(s *State, user database.User) error {
+	if s.Config.SessionToken != "" {
+		if err := s.DB.DeleteSession(s.Ctx, hashToken(s.Config.SessionToken)); err != nil {
+			return err
+		}
+	}
+	if _, err := s.DB.DeleteExpiredSessions(s.Ctx); err != nil {
+		return err
+	}
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return err
+	}
+	token := hex.EncodeToString(raw)
+	err := s.DB.CreateSession(s.Ctx, database.CreateSessionParams{
+		TokenHash: hashToken(token),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(sessionLifetime),
+	})
+	if err != nil {
+		return err
+	}
+	return s.Config.SetSession(user.Name, token)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// HandlerPasswd changes the current user's password. With --init it instead
+// sets the first password of an account that has none, such as those created
+// before gator had passwords. That needs no login, as anyone who can run it
+// can already write to the database.
+func HandlerPasswd(s *State, cmd Command) error {
+	flags := flag.NewFlagSet("passwd", flag.ContinueOnError)
+	initName := flags.String("init", "", "set the first password of this user, who has none yet")
+	if err := flags.Parse(cmd.Args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return fmt.Errorf("Usage: gator passwd [--init <username>]")
+	}
+	if *initName != "" {
+		return initPassword(s, *initName)
+	}
+	return MiddlewareLoggedIn(changePassword)(s, cmd)
+}
+
+// initPassword sets the first password of the user called name and logs them
+// in. Users who already have a password are refused.
+func initPassword(s *State, name string) error {
+	user, err := s.DB.GetUser(s.Ctx, name)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("User %s not found", name)
+	}
+	if err != nil {
+		return err
+	}
+	if user.PasswordHash != "" {
+		return fmt.Errorf("User %s already has a password, use gator passwd to change it", name)
+	}
+	hash, err := readNewPassword(s.Ctx)
+	if err != nil {
+		return err
+	}
+	set, err := s.DB.SetInitialUserPassword(s.Ctx, database.SetInitialUserPasswordParams{
+		ID:           user.ID,
+		PasswordHash: hash,
+	})
+	if err != nil {
+		return err
+	}
+	// Someone else set it while we were prompting.
+	if set == 0 {
+		return fmt.Errorf("User %s already has a password, use gator passwd to change it", name)
+	}
+	if err := startSession(s, user); err != nil {
+		return err
+	}
+	fmt.Printf("Password set, logged in as %s\n", user.Name)
+	return nil
+}
+
+func changePassword(s *State, cmd Command, user database.User) error {
+	current, err := readPassword(s.Ctx, "Current password: ")
+	if err != nil {
+		return err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(current)) != nil {
+		return fmt.Errorf("Incorrect password")
+	}
+	hash, err := readNewPassword(s.Ctx)
+	if err != nil {
+		return err
+	}
+	err = s.DB.SetUserPassword(s.Ctx, database.SetUserPasswordParams{
+		ID:           user.ID,
+		PasswordHash: hash,
+	})
+	if err != nil {
+		return err
+	}
+	// Changing the password signs out every other session.
+	if err := s.DB.DeleteSessionsForUser(s.Ctx, user.ID); err != nil {
+		return err
+	}
+	if err := startSession(s, user); err != nil {
+		return err
+	}
+	fmt.Println("Password changed")
+	return nil
+}
@@ -0,0 +1,137 @@
+package commands
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/UUest/gator/internal/backup"
+	"github.com/UUest/gator/internal/database"
+)
+
+func HandlerBackup(s *State, cmd Command, user database.User) error {
+	path := cmd.Args[0]
+	if err := writeArchive(s, path); err != nil {
+		return err
+	}
+	fmt.Printf("Backup written to %s\n", path)
+	return nil
+}
+
+// HandlerRestore merges an archive into the database in a single transaction,
+// so a failed restore leaves the database unchanged.
+func HandlerRestore(s *State, cmd Command, user database.User) error {
+	f, err := os.Open(cmd.Args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	ctx := s.Ctx
+	tx, err := s.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stats, err := backup.Restore(ctx, s.DB.WithTx(tx), f)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Restored %s\n", cmd.Args[0])
+	for _, recordType := range []string{
+		backup.TypeUser,
+		backup.TypeFeed,
+		backup.TypeFeedFollow,
+		backup.TypePost,
+		backup.TypePostState,
+		backup.TypeEnclosure,
+		backup.TypePodcastDownload,
+		backup.TypeFilterRule,
+		backup.TypeSavedSearch,
+		backup.TypeSearchMatch,
+		backup.TypeWebhook,
+		backup.TypePrunedPost,
+	} {
+		if c, ok := stats[recordType]; ok {
+			fmt.Printf("%s: %d added, %d already present\n", recordType, c.Added, c.Read-c.Added)
+		}
+	}
+	return nil
+}
+
+// writeSnapshot saves a full archive of the database into the configured
+// backup directory and returns its path. label describes why it was taken.
+func writeSnapshot(s *State, label string) (string, error) {
+	dir := s.Config.BackupDirectory()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	// Snapshots taken within the same second get a numbered suffix rather
+	// than replacing each other.
+	base := fmt.Sprintf("%s-%s", label, time.Now().Format("20060102-150405"))
+	for n := 1; ; n++ {
+		name := base + ".jsonl.gz"
+		if n > 1 {
+			name = fmt.Sprintf("%s-%d.jsonl.gz", base, n)
+		}
+		path := filepath.Join(dir, name)
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		if err := writeArchiveTo(s, f); err != nil {
+			os.Remove(path)
+			return "", err
+		}
+		return path, nil
+	}
+}
+
+// writeArchive writes a full archive of the database to path. It is written
+// to a temporary file next to path first and renamed into place once
+// complete, so a failed backup neither leaves a partial archive nor destroys
+// an existing one.
+func writeArchive(s *State, path string) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := writeArchiveTo(s, f); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+// writeArchiveTo writes a full archive of the database to f and closes it.
+// The archive is read in one repeatable read transaction, so posts agg
+// stores meanwhile can't leave it with enclosures or states for posts it
+// doesn't contain.
+func writeArchiveTo(s *State, f *os.File) error {
+	tx, err := s.Conn.BeginTx(s.Ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := backup.Write(s.Ctx, s.DB.WithTx(tx), f); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -3,24 +3,67 @@ package commands
 import (
 	"context"
 	"database/sql"
-	"encoding/xml"
+	"errors"
+	"flag"
 	"fmt"
 	"html"
-	"io"
+	"log/slog"
+	"math"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/term"
 
 	"github.com/UUest/gator/internal/config"
 	"github.com/UUest/gator/internal/database"
+	"github.com/UUest/gator/internal/fetch"
+	"github.com/UUest/gator/internal/readability"
+	"github.com/UUest/gator/internal/render"
 )
 
+const (
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
+// feedLease is how long a claimed feed is left alone by other agg processes.
+// A scrape normally releases its claim well before then; the lease only runs
+// out when a process dies mid-scrape.
+const feedLease = 10 * time.Minute
+
+// shutdownGrace is how long agg lets an in-flight scrape finish after being
+// asked to stop.
+const shutdownGrace = 15 * time.Second
+
+// maxFeedDeferral caps how long a feed is left alone when its server answers
+// 429 or 503 with a Retry-After header.
+const maxFeedDeferral = 24 * time.Hour
+
+// redirectConfirmations is how many fetches in a row must be permanently
+// redirected to the same URL before the feed's URL is updated, so a single
+// misconfigured response can't move a feed.
+const redirectConfirmations = 3
+
 type State struct {
+	// Ctx is cancelled when gator is asked to stop, by Ctrl-C or SIGTERM.
+	Ctx    context.Context
 	Config *config.Config
 	DB     *database.Queries
+	// Conn is the connection pool behind DB, for work that needs a
+	// transaction.
+	Conn *sql.DB
+	// Fetcher makes every outgoing HTTP request.
+	Fetcher *fetch.Fetcher
+	// Logger is for diagnostics from long-running work such as agg, as
+	// opposed to a command's results, which are printed to stdout.
+	Logger *slog.Logger
 }
 
 type Command struct {
@@ -39,18 +82,75 @@ type RSSFeed struct {
 		Description string    `xml:"description"`
 		Items       []RSSItem `xml:"item"`
 	} `xml:"channel"`
+	// MovedTo is the URL the feed was fetched from when every redirect on the
+	// way there was permanent (301 or 308). It is empty when there was no
+	// redirect or any of them was temporary.
+	MovedTo string `xml:"-"`
+	// ParseError is why the strict XML decoder rejected the feed when it
+	// could only be read in lenient mode.
+	ParseError error `xml:"-"`
+	// Size is how many bytes were downloaded, after decompression.
+	Size int `xml:"-"`
+}
+
+// ContentError is returned by FetchFeed when the feed was downloaded but could
+// not be decoded or parsed.
+type ContentError struct {
+	// Size is how many bytes were downloaded, after decompression.
+	Size int
+	Err  error
 }
 
+func (e *ContentError) Error() string { return e.Err.Error() }
+
+func (e *ContentError) Unwrap() error { return e.Err }
+
 type RSSItem struct {
-	Title       string `xml:"title"`
-	Link        string `xml:"link"`
-	Description string `xml:"description"`
-	PubDate     string `xml:"pubDate"`
+	Title          string         `xml:"title"`
+	Link           string         `xml:"link"`
+	Description    string         `xml:"description"`
+	PubDate        string         `xml:"pubDate"`
+	ContentEncoded string         `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	Author         string         `xml:"author"`
+	Creator        string         `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Categories     []string       `xml:"category"`
+	Comments       string         `xml:"comments"`
+	Enclosures     []RSSEnclosure `xml:"enclosure"`
+}
+
+type RSSEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length int64  `xml:"length,attr"`
+}
+
+// AuthorName prefers dc:creator, which feeds use for display names, over the
+// RSS author element, which is meant to hold an email address.
+func (item RSSItem) AuthorName() string {
+	if item.Creator != "" {
+		return item.Creator
+	}
+	return item.Author
+}
+
+// CategoryList returns the item's categories, empty rather than nil so they
+// are stored as an empty array.
+func (item RSSItem) CategoryList() []string {
+	if item.Categories == nil {
+		return []string{}
+	}
+	return item.Categories
 }
 
 func MiddlewareLoggedIn(handler func(s *State, cmd Command, user database.User) error) func(*State, Command) error {
 	return func(s *State, cmd Command) error {
-		user, err := s.DB.GetUser(context.Background(), s.Config.CurrentUserName)
+		if s.Config.SessionToken == "" {
+			return fmt.Errorf("Not logged in, run gator login <username>")
+		}
+		user, err := s.DB.GetSessionUser(s.Ctx, hashToken(s.Config.SessionToken))
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("Session expired, run gator login <username>")
+		}
 		if err != nil {
 			return err
 		}
@@ -58,23 +158,42 @@ func MiddlewareLoggedIn(handler func(s *State, cmd Command, user database.User)
 	}
 }
 
+// MiddlewareAdmin is MiddlewareLoggedIn for commands that only admins may run.
+func MiddlewareAdmin(handler func(s *State, cmd Command, user database.User) error) func(*State, Command) error {
+	return MiddlewareLoggedIn(func(s *State, cmd Command, user database.User) error {
+		if user.Role != RoleAdmin {
+			return fmt.Errorf("The %s command requires an admin account", cmd.Name)
+		}
+		return handler(s, cmd, user)
+	})
+}
+
 func HandlerLogin(s *State, cmd Command) error {
 	if cmd.Args == nil {
 		return fmt.Errorf("Expected a username")
 	}
-	_, err := s.DB.GetUser(context.Background(), cmd.Args[0])
-	if err == sql.ErrNoRows {
-		fmt.Println("User does not exist, please register first")
-		os.Exit(1)
+	user, err := s.DB.GetUser(s.Ctx, cmd.Args[0])
+	if err != nil && err != sql.ErrNoRows {
+		return err
 	}
+	password, err := readPassword(s.Ctx, "Password: ")
 	if err != nil {
 		return err
 	}
-	err = s.Config.SetUser(cmd.Args[0])
-	if err != nil {
+	// Unknown users and accounts without a password, which have to be set
+	// up with gator passwd --init, fail the same way as a wrong password,
+	// so login doesn't reveal which usernames exist.
+	hash := []byte(user.PasswordHash)
+	if len(hash) == 0 {
+		hash = dummyPasswordHash()
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil || user.PasswordHash == "" {
+		return fmt.Errorf("Incorrect username or password")
+	}
+	if err := startSession(s, user); err != nil {
 		return err
 	}
-	fmt.Printf("Username set to %s\n", cmd.Args[0])
+	fmt.Printf("Logged in as %s\n", user.Name)
 	return nil
 }
 
@@ -82,51 +201,166 @@ func HandlerRegister(s *State, cmd Command) error {
 	if cmd.Args == nil {
 		return fmt.Errorf("Expected a username")
 	}
-	existingUser, err := s.DB.GetUser(context.Background(), cmd.Args[0])
+	_, err := s.DB.GetUser(s.Ctx, cmd.Args[0])
 	if err != nil && err != sql.ErrNoRows {
 		return err
 	}
-	if err != nil && existingUser.Name == cmd.Args[0] {
+	if err == nil {
 		fmt.Println("User already exists")
 		os.Exit(1)
 	}
+	hash, err := readNewPassword(s.Ctx)
+	if err != nil {
+		return err
+	}
 	userParams := database.CreateUserParams{
-		ID:        uuid.New(),
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-		Name:      cmd.Args[0],
+		ID:           uuid.New(),
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+		Name:         cmd.Args[0],
+		PasswordHash: hash,
+	}
+	// The first user becomes admin. Taking the lock first serializes
+	// registrations, so two at once cannot both find no users.
+	tx, err := s.Conn.BeginTx(s.Ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	q := s.DB.WithTx(tx)
+	if err := q.LockUserCreation(s.Ctx); err != nil {
+		return err
 	}
-	dbUser, err := s.DB.CreateUser(context.Background(), userParams)
+	dbUser, err := q.CreateUser(s.Ctx, userParams)
 	if err != nil {
 		return err
 	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
 	fmt.Printf("User %s registered\n", dbUser.Name)
 	fmt.Printf("ID: %s\n", dbUser.ID)
 	fmt.Printf("Created at: %s\n", dbUser.CreatedAt)
 	fmt.Printf("Updated at: %s\n", dbUser.UpdatedAt)
 	fmt.Printf("Name: %s\n", dbUser.Name)
-	HandlerLogin(s, cmd)
+	if err := startSession(s, dbUser); err != nil {
+		return err
+	}
+	fmt.Printf("Logged in as %s\n", dbUser.Name)
 	return nil
 }
 
-func HandlerReset(s *State, cmd Command) error {
-	if err := s.DB.Reset(context.Background()); err != nil {
+// HandlerReset deletes data after confirmation, writing a snapshot of the
+// whole database to the backup directory first. Without a scope flag every
+// user, and through them every feed and post, is deleted.
+func HandlerReset(s *State, cmd Command, user database.User) error {
+	flags := flag.NewFlagSet("reset", flag.ContinueOnError)
+	yes := flags.Bool("yes", false, "skip the confirmation prompt")
+	postsOnly := flags.Bool("posts-only", false, "delete posts but keep users, feeds and follows")
+	userName := flags.String("user", "", "delete only this user")
+	transferTo := flags.String("transfer-to", "", "with --user, give the user's feeds to this user first")
+	deleteFeeds := flags.Bool("delete-feeds", false, "with --user, delete the user's feeds along with them")
+	feedURL := flags.String("feed", "", "delete only this feed")
+	if err := flags.Parse(cmd.Args); err != nil {
+		return err
+	}
+	if *userName != "" && (*feedURL != "" || *postsOnly) {
+		return fmt.Errorf("--user cannot be combined with --feed or --posts-only")
+	}
+	if *userName == "" && (*transferTo != "" || *deleteFeeds) {
+		return fmt.Errorf("--transfer-to and --delete-feeds only apply to --user")
+	}
+	if *userName != "" {
+		// Deleting a single user is exactly user delete, including its
+		// last-admin check and handling of the feeds the user added.
+		args := []string{}
+		if *transferTo != "" {
+			args = append(args, "--transfer-to", *transferTo)
+		}
+		if *deleteFeeds {
+			args = append(args, "--delete-feeds")
+		}
+		if *yes {
+			args = append(args, "--yes")
+		}
+		return handlerUserDelete(s, Command{Name: "user", Args: append(args, *userName)}, user)
+	}
+
+	ctx := s.Ctx
+	var description string
+	var reset func() error
+	switch {
+	case *feedURL != "":
+		feed, err := s.DB.GetFeedByURL(ctx, *feedURL)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("Feed %s not found", *feedURL)
+		}
+		if err != nil {
+			return err
+		}
+		if *postsOnly {
+			description = fmt.Sprintf("every post from feed %s", feed.Name)
+			reset = func() error { return s.DB.DeleteFeedPosts(ctx, feed.ID) }
+		} else {
+			description = fmt.Sprintf("feed %s with its posts and follows", feed.Name)
+			reset = func() error { return s.DB.DeleteFeed(ctx, feed.ID) }
+		}
+	case *postsOnly:
+		description = "every post"
+		reset = func() error { return s.DB.DeleteAllPosts(ctx) }
+	default:
+		description = "every user, feed and post"
+		reset = func() error { return s.DB.Reset(ctx) }
+	}
+
+	if !*yes {
+		ok, err := confirm(s.Ctx, fmt.Sprintf("This will permanently delete %s.", description))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Println("Reset cancelled")
+			return nil
+		}
+	}
+	path, err := writeSnapshot(s, "reset")
+	if err != nil {
+		return fmt.Errorf("Could not write backup, nothing was deleted: %w", err)
+	}
+	fmt.Printf("Backup written to %s\n", path)
+	if err := reset(); err != nil {
 		return err
 	}
 	fmt.Println("Database reset")
 	return nil
 }
 
+// confirm asks the user to type "yes" to go ahead with a destructive action.
+func confirm(ctx context.Context, warning string) (bool, error) {
+	fmt.Printf("%s Type 'yes' to continue: ", warning)
+	line, err := readInput(ctx, func() (string, error) {
+		return stdinReader.ReadString('\n')
+	})
+	if err != nil && line == "" {
+		return false, err
+	}
+	return strings.TrimSpace(line) == "yes", nil
+}
+
 func HandlerGetUsers(s *State, cmd Command) error {
-	users, err := s.DB.GetUsers(context.Background())
+	users, err := s.DB.GetUsers(s.Ctx)
 	if err != nil {
 		return err
 	}
 	for _, user := range users {
+		label := user.Name
+		if user.Role == RoleAdmin {
+			label += " [admin]"
+		}
 		if user.Name == s.Config.CurrentUserName {
-			fmt.Printf("* %s (current)\n", user.Name)
+			fmt.Printf("* %s (current)\n", label)
 		} else {
-			fmt.Printf("* %s\n", user.Name)
+			fmt.Printf("* %s\n", label)
 		}
 	}
 	return nil
@@ -147,47 +381,296 @@ func (c *Commands) Register(name string, f func(*State, Command) error) {
 	c.Names[name] = f
 }
 
-func FetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
+// FetchFeed downloads and parses the RSS feed at feedURL. A feed the server
+// has removed returns fetch.ErrGone, and one that was downloaded but could not
+// be read returns a *ContentError.
+func FetchFeed(ctx context.Context, fetcher *fetch.Fetcher, feedURL string) (*RSSFeed, error) {
+	started := time.Now()
+	resp, err := fetcher.Get(ctx, feedURL)
+	observeFetch(feedURL, started, resp, err)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("User-Agent", "gator")
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	data, err := resp.XMLBody()
 	if err != nil {
-		return nil, err
+		return nil, &ContentError{Size: len(resp.Body), Err: err}
 	}
-	defer resp.Body.Close()
-	xmlData, err := io.ReadAll(resp.Body)
+	feed, err := parseFeed(data)
 	if err != nil {
-		return nil, err
+		feedParseFailures.Inc("failed")
+		return nil, &ContentError{Size: len(resp.Body), Err: err}
 	}
-	var feed RSSFeed
-	if err := xml.Unmarshal(xmlData, &feed); err != nil {
-		return nil, err
+	if feed.ParseError != nil {
+		feedParseFailures.Inc("recovered")
 	}
-	html.UnescapeString(feed.Channel.Title)
-	html.UnescapeString(feed.Channel.Description)
-	for _, item := range feed.Channel.Items {
-		html.UnescapeString(item.Title)
-		html.UnescapeString(item.Description)
+	// Titles are plain text, so any HTML entities left after XML decoding are
+	// resolved here. Descriptions stay HTML and are decoded by the renderer.
+	// Control characters are dropped from everything printed as is.
+	feed.Channel.Title = render.StripControl(html.UnescapeString(feed.Channel.Title))
+	feed.Channel.Description = html.UnescapeString(feed.Channel.Description)
+	for i := range feed.Channel.Items {
+		item := &feed.Channel.Items[i]
+		item.Title = render.StripControl(html.UnescapeString(item.Title))
+		item.Link = render.StripControl(item.Link)
+		item.Author = render.StripControl(item.Author)
+		item.Creator = render.StripControl(item.Creator)
+		item.Comments = render.StripControl(item.Comments)
+		for j := range item.Categories {
+			item.Categories[j] = render.StripControl(item.Categories[j])
+		}
+		for j := range item.Enclosures {
+			item.Enclosures[j].URL = render.StripControl(item.Enclosures[j].URL)
+			item.Enclosures[j].Type = render.StripControl(item.Enclosures[j].Type)
+		}
+	}
+	feed.MovedTo = resp.Moved(feedURL)
+	feed.Size = len(resp.Body)
+	return feed, nil
+}
+
+// FetchArticle downloads the page at articleURL and returns its main content
+// as an HTML fragment.
+func FetchArticle(ctx context.Context, fetcher *fetch.Fetcher, articleURL string) (string, error) {
+	resp, err := fetcher.Get(ctx, articleURL)
+	if err != nil {
+		return "", err
+	}
+	body, err := resp.HTMLReader()
+	if err != nil {
+		return "", err
 	}
-	return &feed, nil
+	return readability.Extract(body)
 }
 
+// HandlerAgg scrapes a feed every interval, and prunes old posts every
+// pruneInterval, until gator is asked to stop. Webhook deliveries are sent
+// after each scrape, and retries that fall due in between are picked up every
+// webhookPollInterval. A scrape that is running when gator is asked to stop
+// gets shutdownGrace to finish, and SIGHUP rereads the config file. With
+// --once it instead scrapes every due feed a single time, see aggOnce.
 func HandlerAgg(s *State, cmd Command, user database.User) error {
-	fmt.Printf("Collecting feeds every %s\n", cmd.Args[0])
-	reqTime, err := time.ParseDuration(cmd.Args[0])
+	flags := flag.NewFlagSet("agg", flag.ContinueOnError)
+	once := flags.Bool("once", false, "fetch every due feed once and exit")
+	feedURL := flags.String("feed", "", "with --once, fetch only this feed")
+	metricsAddr := flags.String("metrics", "", "serve Prometheus metrics on this address, e.g. :9090")
+	if err := flags.Parse(cmd.Args); err != nil {
+		return err
+	}
+	if *once {
+		if *metricsAddr != "" {
+			return fmt.Errorf("--metrics can't be used with --once")
+		}
+		if flags.NArg() > 1 {
+			return fmt.Errorf("Usage: gator agg --once [--feed <feed_url>] [min_age]")
+		}
+		var minAge time.Duration
+		if flags.NArg() == 1 {
+			var err error
+			minAge, err = time.ParseDuration(flags.Arg(0))
+			if err != nil {
+				return err
+			}
+		}
+		return aggOnce(s, user, *feedURL, minAge)
+	}
+	if *feedURL != "" {
+		return fmt.Errorf("--feed can only be used with --once")
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("Usage: gator agg [--metrics <addr>] <interval>")
+	}
+
+	reqTime, err := time.ParseDuration(flags.Arg(0))
 	if err != nil {
 		return err
 	}
+	s.Logger.Info("collecting feeds", "user", user.Name, "interval", reqTime)
+	if *metricsAddr != "" {
+		stopMetrics, err := serveMetrics(s.Logger, *metricsAddr)
+		if err != nil {
+			return err
+		}
+		defer stopMetrics()
+	}
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	started := time.Now()
+	var lastPruned time.Time
+	scrapes, failures := 0, 0
 	ticker := time.NewTicker(reqTime)
-	for ; ; <-ticker.C {
-		ScrapeFeeds(s, cmd, user)
+	defer ticker.Stop()
+	// Webhook retries fall due between scrapes, so they are checked for on
+	// their own, shorter schedule.
+	webhookTicker := time.NewTicker(webhookPollInterval)
+	defer webhookTicker.Stop()
+	for {
+		ctx, cancel := graceContext(s.Ctx, shutdownGrace)
+		stopping := context.AfterFunc(s.Ctx, func() {
+			s.Logger.Info("stopping, waiting for the current scrape to finish", "grace", shutdownGrace)
+		})
+		err := ScrapeFeeds(ctx, s, user)
+		stopping()
+		cancel()
+		scrapes++
+		// ScrapeFeeds has already logged the error with the feed it was for.
+		if err != nil {
+			failures++
+		}
+		if s.Ctx.Err() == nil {
+			sendDueWebhooks(s)
+		}
+		if time.Since(lastPruned) >= pruneInterval && s.Ctx.Err() == nil {
+			lastPruned = time.Now()
+			if err := autoPrune(s.Ctx, s); err != nil && s.Ctx.Err() == nil {
+				s.Logger.Error("pruning failed", "err", err)
+			}
+		}
+		if *metricsAddr != "" {
+			if err := observeBacklog(s, user, reqTime); err != nil && s.Ctx.Err() == nil {
+				s.Logger.Warn("could not measure the fetch backlog", "user", user.Name, "err", err)
+			}
+		}
+
+	wait:
+		for {
+			select {
+			case <-s.Ctx.Done():
+				s.Logger.Info("stopped", "user", user.Name, "uptime", time.Since(started).Round(time.Second), "scrapes", scrapes, "failed", failures)
+				return nil
+			case <-hangup:
+				if err := reloadConfig(s); err != nil {
+					s.Logger.Error("could not reload config, keeping the old one", "err", err)
+				} else {
+					s.Logger.Info("config reloaded")
+				}
+			case <-webhookTicker.C:
+				sendDueWebhooks(s)
+			case <-ticker.C:
+				break wait
+			}
+		}
 	}
 }
 
+// aggOnce scrapes each of user's feeds that has not been fetched within
+// minAge, or just the feed at feedURL, one after another. It logs a line per
+// feed and returns an error if any of them failed, so cron and CI jobs see a
+// non-zero exit status.
+func aggOnce(s *State, user database.User, feedURL string, minAge time.Duration) error {
+	var feeds []database.Feed
+	if feedURL != "" {
+		feed, err := s.DB.GetFeedByURL(s.Ctx, feedURL)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("Feed %s not found", feedURL)
+		}
+		if err != nil {
+			return err
+		}
+		feeds = append(feeds, feed)
+		// A feed asked for by URL is fetched however recently it was.
+		minAge = 0
+	} else {
+		var err error
+		feeds, err = s.DB.GetDueFeeds(s.Ctx, database.GetDueFeedsParams{
+			UserID:        user.ID,
+			MinAgeSeconds: minAge.Seconds(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if len(feeds) == 0 {
+		s.Logger.Info("no feeds due", "user", user.Name)
+		return nil
+	}
+
+	failed := 0
+	for _, feed := range feeds {
+		if s.Ctx.Err() != nil {
+			break
+		}
+		log := feedLogger(s, user, feed)
+		claimed, err := s.DB.ClaimFeed(s.Ctx, database.ClaimFeedParams{
+			LeaseSeconds:  feedLease.Seconds(),
+			ID:            feed.ID,
+			MinAgeSeconds: minAge.Seconds(),
+		})
+		if err == sql.ErrNoRows {
+			log.Info("skipped, being fetched by another agg process or deferred at the server's request")
+			continue
+		}
+		if err != nil {
+			failed++
+			log.Error("could not claim feed", "err", err)
+			continue
+		}
+		ctx, cancel := graceContext(s.Ctx, shutdownGrace)
+		added, err := scrapeFeed(ctx, s, log, claimed)
+		cancel()
+		if err != nil {
+			failed++
+			log.Error("fetch failed", "err", err)
+			continue
+		}
+		log.Info("fetched feed", "new_posts", added)
+	}
+	if err := s.Ctx.Err(); err != nil {
+		return err
+	}
+	// Deliveries that fail now are retried by the next run.
+	sendDueWebhooks(s)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d feeds failed", failed, len(feeds))
+	}
+	return nil
+}
+
+// graceContext returns a context that is cancelled grace after parent is, so
+// work started before a shutdown can still finish its database writes.
+func graceContext(parent context.Context, grace time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(parent))
+	stop := context.AfterFunc(parent, func() {
+		timer := time.NewTimer(grace)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			cancel()
+		case <-ctx.Done():
+		}
+	})
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// reloadConfig rereads the config file and rebuilds the fetcher and logger
+// from it. The database connection is kept, so a new db_url needs a restart.
+func reloadConfig(s *State) error {
+	cfg, err := config.Read()
+	if err != nil {
+		return err
+	}
+	opts, err := cfg.FetchOptions()
+	if err != nil {
+		return err
+	}
+	fetcher, err := fetch.New(opts)
+	if err != nil {
+		return err
+	}
+	logger, err := cfg.Logger(os.Stderr)
+	if err != nil {
+		return err
+	}
+	s.Config = cfg
+	s.Fetcher = fetcher
+	s.Logger = logger
+	return nil
+}
+
 func HandlerAddFeed(s *State, cmd Command, user database.User) error {
 	if len(cmd.Args) != 2 {
 		fmt.Println("Usage: addfeed <feed_name> <feed_url>")
@@ -203,7 +686,7 @@ func HandlerAddFeed(s *State, cmd Command, user database.User) error {
 		Url:       feedURL,
 		UserID:    user.ID,
 	}
-	feed, err := s.DB.AddFeed(context.Background(), feedParams)
+	feed, err := s.DB.AddFeed(s.Ctx, feedParams)
 	if err != nil {
 		return err
 	}
@@ -211,7 +694,7 @@ func HandlerAddFeed(s *State, cmd Command, user database.User) error {
 		UserID: user.ID,
 		FeedID: feed.ID,
 	}
-	_, err = s.DB.CreateFeedFollow(context.Background(), feedFollowParams)
+	_, err = s.DB.CreateFeedFollow(s.Ctx, feedFollowParams)
 	if err != nil {
 		return err
 	}
@@ -227,8 +710,11 @@ func HandlerAddFeed(s *State, cmd Command, user database.User) error {
 	return nil
 }
 
-func HandlerGetFeeds(s *State, cmd Command) error {
-	feeds, err := s.DB.GetFeeds(context.Background())
+// HandlerGetFeeds lists every feed in the database, not just the ones the
+// user follows, so users can find feeds others added and follow them by URL.
+// Use following for the user's own subscriptions.
+func HandlerGetFeeds(s *State, cmd Command, user database.User) error {
+	feeds, err := s.DB.GetFeeds(s.Ctx)
 	if err != nil {
 		return err
 	}
@@ -238,21 +724,26 @@ func HandlerGetFeeds(s *State, cmd Command) error {
 	}
 	fmt.Println("Feeds:")
 	for _, feed := range feeds {
-		userName, err := s.DB.GetUserById(context.Background(), feed.UserID)
+		userName, err := s.DB.GetUserById(s.Ctx, feed.UserID)
 		if err != nil {
 			return err
 		}
 		fmt.Printf("Feed Name: %s\n", feed.Name)
 		fmt.Printf("Feed URL: %s\n", feed.Url)
 		fmt.Printf("Feed User: %s\n", userName.Name)
-		fmt.Printf("Feed UserID: %s\n", feed.UserID)
+		if user.Role == RoleAdmin {
+			fmt.Printf("Feed UserID: %s\n", feed.UserID)
+		}
+		if feed.RetiredAt.Valid {
+			fmt.Printf("Feed Retired: %s\n", feed.RetiredAt.Time.Format(time.DateOnly))
+		}
 	}
 	return nil
 }
 
 func HandlerFollow(s *State, cmd Command, user database.User) error {
 	url := cmd.Args[0]
-	feed, err := s.DB.GetFeedByURL(context.Background(), url)
+	feed, err := s.DB.GetFeedByURL(s.Ctx, url)
 	if err != nil {
 		return err
 	}
@@ -260,14 +751,17 @@ func HandlerFollow(s *State, cmd Command, user database.User) error {
 		UserID: user.ID,
 		FeedID: feed.ID,
 	}
-	feedFollow, err := s.DB.CreateFeedFollow(context.Background(), feedFollowParams)
+	feedFollow, err := s.DB.CreateFeedFollow(s.Ctx, feedFollowParams)
+	if err != nil {
+		return err
+	}
 	fmt.Printf("Feed Name: %s\n", feedFollow.FeedName)
 	fmt.Printf("Feed User: %s\n", feedFollow.UserName)
 	return nil
 }
 
 func HandlerFollowing(s *State, cmd Command, user database.User) error {
-	follows, err := s.DB.GetFeedFollowsForUser(context.Background(), user.ID)
+	follows, err := s.DB.GetFeedFollowsForUser(s.Ctx, user.ID)
 	if err != nil {
 		return err
 	}
@@ -285,7 +779,7 @@ func HandlerFollowing(s *State, cmd Command, user database.User) error {
 
 func HandlerUnfollow(s *State, cmd Command, user database.User) error {
 	url := cmd.Args[0]
-	feed, err := s.DB.GetFeedByURL(context.Background(), url)
+	feed, err := s.DB.GetFeedByURL(s.Ctx, url)
 	if err != nil {
 		return err
 	}
@@ -293,7 +787,7 @@ func HandlerUnfollow(s *State, cmd Command, user database.User) error {
 		UserID: user.ID,
 		FeedID: feed.ID,
 	}
-	_, err = s.DB.UnfollowFeed(context.Background(), feedFollowParams)
+	_, err = s.DB.UnfollowFeed(s.Ctx, feedFollowParams)
 	if err != nil {
 		return err
 	}
@@ -301,37 +795,275 @@ func HandlerUnfollow(s *State, cmd Command, user database.User) error {
 	return nil
 }
 
-func ScrapeFeeds(s *State, cmd Command, user database.User) error {
-	nextFeed, err := s.DB.GetNextFeedToFetch(context.Background(), user.ID)
+// ScrapeFeeds fetches whichever of user's feeds was fetched least recently
+// and stores its new posts. Feeds that another agg process is working on are
+// skipped, so any number of them can share the work.
+func ScrapeFeeds(ctx context.Context, s *State, user database.User) error {
+	nextFeed, err := s.DB.ClaimNextFeedToFetch(ctx, database.ClaimNextFeedToFetchParams{
+		LeaseSeconds: feedLease.Seconds(),
+		UserID:       user.ID,
+	})
+	if err == sql.ErrNoRows {
+		s.Logger.Info("no feeds to fetch", "user", user.Name)
+		return nil
+	}
 	if err != nil {
+		s.Logger.Error("could not claim a feed", "user", user.Name, "err", err)
 		return err
 	}
-	_, err = s.DB.MarkFeedFetched(context.Background(), nextFeed.ID)
+	log := feedLogger(s, user, nextFeed)
+	added, err := scrapeFeed(ctx, s, log, nextFeed)
 	if err != nil {
+		log.Error("fetch failed", "err", err)
 		return err
 	}
-	feed, err := FetchFeed(context.Background(), nextFeed.Url)
+	log.Info("fetched feed", "new_posts", added)
+	return nil
+}
+
+// feedLogger returns s.Logger with the feed and the user it is fetched for
+// attached to every line.
+func feedLogger(s *State, user database.User, feed database.Feed) *slog.Logger {
+	return s.Logger.With("user", user.Name, "feed_id", feed.ID, "feed_url", feed.Url)
+}
+
+// scrapeFeed fetches a feed the caller has claimed and stores its posts,
+// returning how many of them were new. The claim is released when it is done,
+// or held for as long as the server asked us to wait with Retry-After, and
+// the attempt is recorded in the feed's fetch history.
+func scrapeFeed(ctx context.Context, s *State, log *slog.Logger, dbFeed database.Feed) (added int, err error) {
+	// The claim is released and the history written even when ctx has been
+	// cancelled, so other processes don't have to wait for the lease to
+	// expire and interrupted fetches still show up in feed inspect.
+	claimedID := dbFeed.ID
+	defer func() {
+		var statusErr *fetch.StatusError
+		if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+			delay := min(statusErr.RetryAfter, maxFeedDeferral)
+			err := s.DB.DeferFeed(context.WithoutCancel(ctx), database.DeferFeedParams{
+				DelaySeconds: delay.Seconds(),
+				ID:           claimedID,
+			})
+			if err == nil {
+				log.Info("server asked us to slow down, feed deferred", "retry_after", delay)
+				return
+			}
+			log.Warn("could not defer feed", "err", err)
+		}
+		if err := s.DB.ReleaseFeed(context.WithoutCancel(ctx), claimedID); err != nil {
+			log.Warn("could not release feed, other agg processes will wait for the lease to expire", "err", err)
+		}
+	}()
+	fetched := database.CreateFeedFetchParams{FetchedAt: time.Now()}
+	defer func() {
+		// After a redirect merged the feed into another one, only that
+		// one is left to hold the history.
+		fetched.FeedID = dbFeed.ID
+		fetched.NewPosts = int32(added)
+		if err != nil {
+			fetched.Error = err.Error()
+		}
+		if err := s.DB.CreateFeedFetch(context.WithoutCancel(ctx), fetched); err != nil {
+			log.Warn("could not record fetch history", "err", err)
+		}
+	}()
+
+	feed, err := FetchFeed(ctx, s.Fetcher, dbFeed.Url)
+	fetched.DurationMs = int32(time.Since(fetched.FetchedAt).Milliseconds())
+	if code := fetchStatusCode(err); code != 0 {
+		fetched.StatusCode = sql.NullInt32{Int32: int32(code), Valid: true}
+	}
+	var contentErr *ContentError
+	if errors.As(err, &contentErr) {
+		fetched.Bytes = int64(contentErr.Size)
+	}
+	if err == fetch.ErrGone {
+		if err := s.DB.RetireFeed(ctx, dbFeed.ID); err != nil {
+			return 0, err
+		}
+		log.Warn("feed is gone and will no longer be fetched", "undo", "gator feed revive "+dbFeed.Url)
+		return 0, nil
+	}
 	if err != nil {
-		return err
+		return 0, err
+	}
+	fetched.Bytes = int64(feed.Size)
+	fetched.ItemsSeen = int32(len(feed.Channel.Items))
+	if feed.ParseError != nil {
+		log.Warn("feed is malformed, parsed leniently", "err", feed.ParseError)
 	}
+	dbFeed, err = followRedirect(ctx, s, log, dbFeed, feed.MovedTo)
+	if err != nil {
+		return 0, err
+	}
+	dbRules, err := s.DB.GetFilterRulesForFeed(ctx, dbFeed.ID)
+	if err != nil {
+		return 0, err
+	}
+	rules := compileFilterRules(log, dbRules)
+	dbSearches, err := s.DB.GetSavedSearchesForFeed(ctx, dbFeed.ID)
+	if err != nil {
+		return 0, err
+	}
+	searches := compileSavedSearches(log, dbSearches)
 	for _, item := range feed.Channel.Items {
-		pubAt, err := time.Parse(time.RFC1123, item.PubDate)
+		// Posts already stored are skipped before their article is fetched.
+		// Edits the feed made since are copied over, keeping the article
+		// readability extracted when the feed has no content of its own.
+		if _, err := s.DB.GetPostIDByURL(ctx, item.Link); err == nil {
+			updated, err := s.DB.UpdatePostFromFeed(ctx, database.UpdatePostFromFeedParams{
+				Title:       item.Title,
+				Description: item.Description,
+				Content:     item.ContentEncoded,
+				Author:      item.AuthorName(),
+				Categories:  item.CategoryList(),
+				CommentsUrl: item.Comments,
+				Url:         item.Link,
+			})
+			if err != nil {
+				return added, err
+			}
+			if updated > 0 {
+				feedPosts.Inc("updated")
+			} else {
+				feedPosts.Inc("existing")
+			}
+			continue
+		} else if err != sql.ErrNoRows {
+			return added, err
+		}
+		// So are posts the retention policy already deleted, which are
+		// remembered for as long as the feed still carries them, see
+		// prunedPostDays.
+		if pruned, err := s.DB.SeePrunedPost(ctx, item.Link); err != nil {
+			return added, err
+		} else if pruned > 0 {
+			feedPosts.Inc("pruned")
+			continue
+		}
+		// An item without a readable date is still stored, as of now,
+		// rather than failing the rest of the feed.
+		pubAt, err := parsePubDate(item.PubDate)
 		if err != nil {
-			return err
+			log.Warn("using the fetch time as publication date", "post_url", item.Link, "err", err)
+			pubAt = time.Now()
+		}
+		// Full content the feed provides itself is kept; readability is
+		// only for items that come with just a teaser.
+		content := item.ContentEncoded
+		if dbFeed.Readability && content == "" {
+			article, err := FetchArticle(ctx, s.Fetcher, item.Link)
+			if err != nil {
+				log.Warn("could not extract article", "post_url", item.Link, "err", err)
+			} else {
+				content = article
+			}
 		}
 		postParams := database.CreatePostParams{
 			Title:       item.Title,
 			Url:         item.Link,
 			PublishedAt: pubAt,
 			Description: item.Description,
-			FeedID:      nextFeed.ID,
+			FeedID:      dbFeed.ID,
+			Content:     content,
+			Author:      item.AuthorName(),
+			Categories:  item.CategoryList(),
+			CommentsUrl: item.Comments,
+		}
+		postID, err := s.DB.CreatePost(ctx, postParams)
+		if err == sql.ErrNoRows {
+			// Another scrape stored the same post in the meantime.
+			feedPosts.Inc("existing")
+			continue
 		}
-		err = s.DB.CreatePost(context.Background(), postParams)
 		if err != nil {
-			return err
+			return added, err
+		}
+		feedPosts.Inc("inserted")
+		added++
+		for _, enclosure := range item.Enclosures {
+			if enclosure.URL == "" {
+				continue
+			}
+			err = s.DB.CreateEnclosure(ctx, database.CreateEnclosureParams{
+				PostID:   postID,
+				Url:      enclosure.URL,
+				MimeType: enclosure.Type,
+				Length:   enclosure.Length,
+			})
+			if err != nil {
+				return added, err
+			}
+		}
+		if err := applyIngestFilters(ctx, s, log, rules, dbFeed.ID, postID, item.Title); err != nil {
+			return added, err
+		}
+		if err := applySavedSearches(ctx, s, log, searches, postID, item.Title, item.Description, content); err != nil {
+			return added, err
+		}
+		queued, err := s.DB.CreateWebhookDeliveries(ctx, database.CreateWebhookDeliveriesParams{
+			PostID: postID,
+			FeedID: dbFeed.ID,
+		})
+		if err != nil {
+			return added, err
+		}
+		if queued > 0 {
+			log.Debug("queued webhook deliveries", "post_id", postID, "deliveries", queued)
 		}
 	}
-	return nil
+	return added, nil
+}
+
+// fetchStatusCode returns the HTTP status of the response a FetchFeed error
+// came from, 200 when there was no error, or 0 when no response was received.
+func fetchStatusCode(err error) int {
+	var contentErr *ContentError
+	var statusErr *fetch.StatusError
+	switch {
+	case err == nil, errors.As(err, &contentErr):
+		return http.StatusOK
+	case errors.Is(err, fetch.ErrGone):
+		return http.StatusGone
+	case errors.As(err, &statusErr):
+		return statusErr.StatusCode
+	}
+	return 0
+}
+
+// followRedirect records that feed was permanently redirected to movedTo and,
+// once the same redirect has been seen redirectConfirmations times in a row,
+// moves the feed there, merging it into any feed that already uses that URL.
+// It returns the feed new posts belong to.
+func followRedirect(ctx context.Context, s *State, log *slog.Logger, feed database.Feed, movedTo string) (database.Feed, error) {
+	if movedTo == "" {
+		return feed, s.DB.ClearFeedRedirect(ctx, feed.ID)
+	}
+	feed, err := s.DB.RecordFeedRedirect(ctx, database.RecordFeedRedirectParams{
+		ID:          feed.ID,
+		RedirectUrl: sql.NullString{String: movedTo, Valid: true},
+	})
+	if err != nil || feed.RedirectCount < redirectConfirmations {
+		return feed, err
+	}
+	target, err := s.DB.GetFeedByURL(ctx, movedTo)
+	if err == sql.ErrNoRows {
+		log.Info("feed moved permanently, updating its URL", "new_url", movedTo)
+		return s.DB.SetFeedURL(ctx, database.SetFeedURLParams{
+			ID:  feed.ID,
+			Url: movedTo,
+		})
+	}
+	if err != nil {
+		return feed, err
+	}
+	posts, follows, err := mergeFeeds(ctx, s, feed, target)
+	if err != nil {
+		return feed, err
+	}
+	log.Info("feed moved permanently, merged into the feed at its new URL",
+		"new_url", movedTo, "merged_into", target.ID, "posts_moved", posts, "new_followers", follows)
+	return target, nil
 }
 
 func HandlerGetPosts(s *State, cmd Command, user database.User) error {
@@ -346,23 +1078,117 @@ func HandlerGetPosts(s *State, cmd Command, user database.User) error {
 			return err
 		}
 	}
-	postParams := database.GetPostsForUserParams{
-		UserID: user.ID,
-		Limit:  int32(limit),
-	}
-	posts, err := s.DB.GetPostsForUser(context.Background(), postParams)
+	rules, err := userFilterRules(s, user)
 	if err != nil {
 		return err
 	}
+	// Posts hidden by filter rules don't count towards the limit, so keep
+	// asking for more until enough are left or there are no more.
+	var posts []database.Post
+	var filters []postFilter
+	for fetchLimit := limit; ; fetchLimit *= 2 {
+		postParams := database.GetPostsForUserParams{
+			UserID: user.ID,
+			Limit:  int32(min(fetchLimit, math.MaxInt32)),
+		}
+		fetched, err := s.DB.GetPostsForUser(s.Ctx, postParams)
+		if err != nil {
+			return err
+		}
+		posts, filters = posts[:0], filters[:0]
+		for _, post := range fetched {
+			pf := evaluateFilters(rules, post.FeedID, post.Title)
+			if pf.hidden || int64(len(posts)) == limit {
+				continue
+			}
+			posts = append(posts, post)
+			filters = append(filters, pf)
+		}
+		if int64(len(posts)) == limit || int64(len(fetched)) < fetchLimit || fetchLimit >= math.MaxInt32 {
+			break
+		}
+	}
 	if len(posts) == 0 {
 		fmt.Println("No posts found")
 		return nil
 	}
+	width := terminalWidth()
 	fmt.Println("Posts:")
-	for _, post := range posts {
-		fmt.Printf("Title: %s\n", post.Title)
-		fmt.Printf("Link: %s\n", post.Url)
+	for i, post := range posts {
+		fmt.Printf("ID: %d\n", post.ID)
+		if filters[i].highlighted {
+			fmt.Printf("Title: [!] %s\n", render.StripControl(post.Title))
+		} else {
+			fmt.Printf("Title: %s\n", render.StripControl(post.Title))
+		}
+		fmt.Printf("Link: %s\n", render.StripControl(post.Url))
 		fmt.Printf("Published At: %s\n", post.PublishedAt.Format(time.RFC1123))
+		if post.Description != "" {
+			fmt.Println()
+			fmt.Println(render.Terminal(post.Description, width))
+		}
+		fmt.Println()
 	}
 	return nil
 }
+
+func HandlerShowPost(s *State, cmd Command, user database.User) error {
+	id, err := strconv.ParseInt(cmd.Args[0], 10, 32)
+	if err != nil {
+		return fmt.Errorf("Invalid post ID: %s", cmd.Args[0])
+	}
+	post, err := s.DB.GetPostForUser(s.Ctx, database.GetPostForUserParams{
+		ID:     int32(id),
+		UserID: user.ID,
+	})
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("Post %d not found in your feeds", id)
+	}
+	if err != nil {
+		return err
+	}
+	enclosures, err := s.DB.GetEnclosuresForPost(s.Ctx, post.ID)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Title: %s\n", render.StripControl(post.Title))
+	fmt.Printf("Link: %s\n", render.StripControl(post.Url))
+	fmt.Printf("Published At: %s\n", post.PublishedAt.Format(time.RFC1123))
+	if post.Author != "" {
+		fmt.Printf("Author: %s\n", render.StripControl(post.Author))
+	}
+	if len(post.Categories) > 0 {
+		fmt.Printf("Categories: %s\n", render.StripControl(strings.Join(post.Categories, ", ")))
+	}
+	if post.CommentsUrl != "" {
+		fmt.Printf("Comments: %s\n", render.StripControl(post.CommentsUrl))
+	}
+	for _, enclosure := range enclosures {
+		fmt.Printf("Enclosure: %s (%s, %d bytes)\n", render.StripControl(enclosure.Url), render.StripControl(enclosure.MimeType), enclosure.Length)
+	}
+	fmt.Println()
+	fmt.Println(render.Terminal(postBody(post.Description, post.Content), terminalWidth()))
+	return s.DB.MarkPostRead(s.Ctx, database.MarkPostReadParams{
+		UserID: user.ID,
+		PostID: post.ID,
+	})
+}
+
+// postBody returns the extracted article content when there is any, and the
+// feed's description otherwise.
+func postBody(description, content string) string {
+	if content != "" {
+		return content
+	}
+	return description
+}
+
+// terminalWidth returns the width to wrap rendered text at, falling back to
+// 80 columns when stdout is not a terminal.
+func terminalWidth() int {
+	width, _, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 {
+		return 80
+	}
+	return width
+}
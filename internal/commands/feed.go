@@ -0,0 +1,428 @@
+package commands
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/UUest/gator/internal/database"
+	"github.com/UUest/gator/internal/render"
+)
+
+// HandlerFeed dispatches the "feed" subcommands that change a single feed.
+func HandlerFeed(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) == 0 {
+		return fmt.Errorf("Expected a feed subcommand")
+	}
+	sub := Command{Name: cmd.Args[0], Args: cmd.Args[1:]}
+	switch sub.Name {
+	case "rename":
+		return handlerFeedRename(s, sub, user)
+	case "set-url":
+		return handlerFeedSetURL(s, sub, user)
+	case "delete":
+		return handlerFeedDelete(s, sub, user)
+	case "readability":
+		return handlerFeedReadability(s, sub, user)
+	case "revive":
+		return handlerFeedRevive(s, sub, user)
+	case "inspect":
+		return handlerFeedInspect(s, sub, user)
+	case "retention":
+		return handlerFeedRetention(s, sub, user)
+	default:
+		return fmt.Errorf("Unknown feed subcommand: %s", sub.Name)
+	}
+}
+
+func handlerFeedRename(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) != 2 {
+		return fmt.Errorf("Usage: gator feed rename <feed_url> <new_name>")
+	}
+	feed, err := getEditableFeed(s, user, cmd.Args[0])
+	if err != nil {
+		return err
+	}
+	renamed, err := s.DB.RenameFeed(s.Ctx, database.RenameFeedParams{
+		ID:   feed.ID,
+		Name: cmd.Args[1],
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Feed %s renamed to %s\n", feed.Name, renamed.Name)
+	return nil
+}
+
+// handlerFeedSetURL points a feed at a new URL. When another feed already uses
+// that URL, the two are merged: posts and followers move to the existing feed
+// and the old one is deleted.
+func handlerFeedSetURL(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) != 2 {
+		return fmt.Errorf("Usage: gator feed set-url <feed_url> <new_url>")
+	}
+	feed, err := getEditableFeed(s, user, cmd.Args[0])
+	if err != nil {
+		return err
+	}
+	newURL := cmd.Args[1]
+	if newURL == feed.Url {
+		return nil
+	}
+
+	ctx := s.Ctx
+	target, err := s.DB.GetFeedByURL(ctx, newURL)
+	if err == sql.ErrNoRows {
+		_, err = s.DB.SetFeedURL(ctx, database.SetFeedURLParams{
+			ID:  feed.ID,
+			Url: newURL,
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Feed %s now fetched from %s\n", feed.Name, newURL)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !canEditFeed(user, target) {
+		return fmt.Errorf("%s already belongs to feed %s, which only its owner or an admin can merge into", newURL, target.Name)
+	}
+	posts, follows, err := mergeFeeds(ctx, s, feed, target)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Merged feed %s into %s: moved %d posts and %d new followers\n", feed.Name, target.Name, posts, follows)
+	return nil
+}
+
+func handlerFeedDelete(s *State, cmd Command, user database.User) error {
+	flags := flag.NewFlagSet("feed delete", flag.ContinueOnError)
+	yes := flags.Bool("yes", false, "skip the confirmation prompt")
+	if err := flags.Parse(cmd.Args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("Usage: gator feed delete [--yes] <feed_url>")
+	}
+	feed, err := getEditableFeed(s, user, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if !*yes {
+		ok, err := confirm(s.Ctx, fmt.Sprintf("This will permanently delete feed %s with its posts and every user's subscription to it.", feed.Name))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Println("Delete cancelled")
+			return nil
+		}
+	}
+	path, err := writeSnapshot(s, "feed-delete")
+	if err != nil {
+		return fmt.Errorf("Could not write backup, nothing was deleted: %w", err)
+	}
+	fmt.Printf("Backup written to %s\n", path)
+	if err := s.DB.DeleteFeed(s.Ctx, feed.ID); err != nil {
+		return err
+	}
+	fmt.Printf("Feed %s deleted\n", feed.Name)
+	return nil
+}
+
+func handlerFeedReadability(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) != 2 || (cmd.Args[1] != "on" && cmd.Args[1] != "off") {
+		return fmt.Errorf("Usage: gator feed readability <feed_url> on|off")
+	}
+	feed, err := getEditableFeed(s, user, cmd.Args[0])
+	if err != nil {
+		return err
+	}
+	feed, err = s.DB.SetFeedReadability(s.Ctx, database.SetFeedReadabilityParams{
+		ID:          feed.ID,
+		Readability: cmd.Args[1] == "on",
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Readability mode for %s is now %s\n", feed.Name, cmd.Args[1])
+	return nil
+}
+
+func handlerFeedRevive(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("Usage: gator feed revive <feed_url>")
+	}
+	feed, err := getEditableFeed(s, user, cmd.Args[0])
+	if err != nil {
+		return err
+	}
+	if !feed.RetiredAt.Valid {
+		fmt.Printf("Feed %s is not retired\n", feed.Name)
+		return nil
+	}
+	if err := s.DB.ReviveFeed(s.Ctx, feed.ID); err != nil {
+		return err
+	}
+	fmt.Printf("Feed %s will be fetched again\n", feed.Name)
+	return nil
+}
+
+// handlerFeedInspect shows a feed's scheduling state, what its recent fetches
+// returned and how often it publishes, to help find out why it looks stale.
+func handlerFeedInspect(s *State, cmd Command, user database.User) error {
+	flags := flag.NewFlagSet("feed inspect", flag.ContinueOnError)
+	limit := flags.Int("limit", 10, "how many recent fetches to list")
+	if err := flags.Parse(cmd.Args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 || *limit < 0 {
+		return fmt.Errorf("Usage: gator feed inspect [--limit <n>] <feed_url>")
+	}
+	feed, err := s.DB.GetFeedByURL(s.Ctx, flags.Arg(0))
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("Feed %s not found", flags.Arg(0))
+	}
+	if err != nil {
+		return err
+	}
+	stats, err := s.DB.GetFeedFetchStats(s.Ctx, feed.ID)
+	if err != nil {
+		return err
+	}
+	posts, err := s.DB.GetFeedPostStats(s.Ctx, feed.ID)
+	if err != nil {
+		return err
+	}
+	fetches, err := s.DB.GetFeedFetches(s.Ctx, database.GetFeedFetchesParams{
+		FeedID: feed.ID,
+		Limit:  int32(*limit),
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Feed Name: %s\n", feed.Name)
+	fmt.Printf("Feed URL: %s\n", feed.Url)
+	if feed.LastFetched.Valid {
+		fmt.Printf("Last Fetched: %s\n", feed.LastFetched.Time.Format(time.DateTime))
+	} else {
+		fmt.Println("Last Fetched: never")
+	}
+	if feed.LockedUntil.Valid {
+		fmt.Printf("Claimed Until: %s, by an agg process\n", feed.LockedUntil.Time.Format(time.DateTime))
+	}
+	if feed.RetiredAt.Valid {
+		fmt.Printf("Retired: %s, not fetched until gator feed revive %s\n", feed.RetiredAt.Time.Format(time.DateOnly), feed.Url)
+	}
+	if feed.RedirectUrl.Valid {
+		fmt.Printf("Redirected To: %s (%d of %d fetches needed to move)\n", render.StripControl(feed.RedirectUrl.String), feed.RedirectCount, redirectConfirmations)
+	}
+	fmt.Printf("Retention: %s\n", feedRetention(s, feed))
+
+	fmt.Println()
+	fmt.Printf("Fetches: %d recorded, %d succeeded\n", stats.Fetches, stats.Succeeded)
+	if stats.Fetches > 0 {
+		avgDuration := time.Duration(stats.AvgDurationMs * float64(time.Millisecond)).Round(time.Millisecond)
+		fmt.Printf("Average Fetch: %.1f items, %.1f new posts, %s\n", stats.AvgItems, stats.AvgNewPosts, avgDuration)
+	}
+	fmt.Printf("Posts: %d stored, %d published in the last 30 days\n", posts.Posts, posts.RecentPosts)
+	if posts.Posts > 1 && posts.SpanSeconds > 0 {
+		interval := time.Duration(posts.SpanSeconds / float64(posts.Posts-1) * float64(time.Second))
+		fmt.Printf("Posting Frequency: about one post every %s\n", approxDuration(interval))
+	}
+
+	if len(fetches) == 0 {
+		return nil
+	}
+	fmt.Println()
+	fmt.Println("Recent Fetches:")
+	for _, f := range fetches {
+		status := "-"
+		if f.StatusCode.Valid {
+			status = fmt.Sprint(f.StatusCode.Int32)
+		}
+		duration := time.Duration(f.DurationMs) * time.Millisecond
+		fmt.Printf("%s  %s  %8d bytes  %7s  %3d items  %3d new\n", f.FetchedAt.Format(time.DateTime), status, f.Bytes, duration, f.ItemsSeen, f.NewPosts)
+		if f.Error != "" {
+			fmt.Printf("    Error: %s\n", render.StripControl(f.Error))
+		}
+	}
+	return nil
+}
+
+// handlerFeedRetention shows a feed's retention policy, or with flags
+// overrides the global one for this feed.
+func handlerFeedRetention(s *State, cmd Command, user database.User) error {
+	flags := flag.NewFlagSet("feed retention", flag.ContinueOnError)
+	posts := flags.Int("posts", 0, "keep only the newest n posts, 0 for no limit")
+	days := flags.Int("days", 0, "keep posts for n days, 0 for no limit")
+	useDefault := flags.Bool("default", false, "follow the global retention policy again")
+	if err := flags.Parse(cmd.Args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("Usage: gator feed retention [--posts <n>] [--days <n>] [--default] <feed_url>")
+	}
+	set := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if *posts < 0 || *days < 0 {
+		return fmt.Errorf("Retention limits can't be negative")
+	}
+	if *useDefault && (set["posts"] || set["days"]) {
+		return fmt.Errorf("--default cannot be combined with --posts or --days")
+	}
+
+	if len(set) == 0 {
+		feed, err := s.DB.GetFeedByURL(s.Ctx, flags.Arg(0))
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("Feed %s not found", flags.Arg(0))
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Retention for %s: %s\n", feed.Name, feedRetention(s, feed))
+		return nil
+	}
+	feed, err := getEditableFeed(s, user, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	params := database.SetFeedRetentionParams{
+		ID:             feed.ID,
+		RetentionPosts: feed.RetentionPosts,
+		RetentionDays:  feed.RetentionDays,
+	}
+	if *useDefault {
+		params.RetentionPosts = sql.NullInt32{}
+		params.RetentionDays = sql.NullInt32{}
+	}
+	if set["posts"] {
+		params.RetentionPosts = sql.NullInt32{Int32: int32(*posts), Valid: true}
+	}
+	if set["days"] {
+		params.RetentionDays = sql.NullInt32{Int32: int32(*days), Valid: true}
+	}
+	feed, err = s.DB.SetFeedRetention(s.Ctx, params)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Retention for %s is now: %s\n", feed.Name, feedRetention(s, feed))
+	return nil
+}
+
+// feedRetention describes the retention policy that applies to feed, which
+// falls back to the global one for any limit the feed doesn't set.
+func feedRetention(s *State, feed database.Feed) string {
+	posts, days := int32(s.Config.RetentionPosts), int32(s.Config.RetentionDays)
+	source := "global default"
+	if feed.RetentionPosts.Valid {
+		posts = feed.RetentionPosts.Int32
+		source = "set for this feed"
+	}
+	if feed.RetentionDays.Valid {
+		days = feed.RetentionDays.Int32
+		source = "set for this feed"
+	}
+	return fmt.Sprintf("%s (%s)", describeRetention(posts, days), source)
+}
+
+// approxDuration formats d to the nearest minute, or in hours or days once
+// it is long enough that minutes are noise.
+func approxDuration(d time.Duration) string {
+	switch {
+	case d >= 48*time.Hour:
+		return fmt.Sprintf("%.1f days", d.Hours()/24)
+	case d >= 2*time.Hour:
+		return fmt.Sprintf("%.1f hours", d.Hours())
+	case d >= time.Minute:
+		return d.Round(time.Minute).String()
+	}
+	return d.Round(time.Second).String()
+}
+
+// mergeFeeds moves the posts and followers of from into to and deletes from,
+// returning how many posts and new followers were moved. Everything else
+// that belongs to from, and would otherwise go with it, moves to to as well:
+// its fetch history, the URLs of its pruned posts, so that they aren't stored
+// again from the new URL, and the filter rules and webhooks limited to it,
+// whose delivery logs go along with them.
+func mergeFeeds(ctx context.Context, s *State, from, to database.Feed) (int64, int64, error) {
+	tx, err := s.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+	q := s.DB.WithTx(tx)
+	posts, err := q.MoveFeedPosts(ctx, database.MoveFeedPostsParams{
+		ToFeedID:   to.ID,
+		FromFeedID: from.ID,
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	follows, err := q.MoveFeedFollows(ctx, database.MoveFeedFollowsParams{
+		ToFeedID:   to.ID,
+		FromFeedID: from.ID,
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	_, err = q.MoveFeedFetches(ctx, database.MoveFeedFetchesParams{
+		ToFeedID:   to.ID,
+		FromFeedID: from.ID,
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	_, err = q.MoveFeedPrunedPosts(ctx, database.MoveFeedPrunedPostsParams{
+		ToFeedID:   to.ID,
+		FromFeedID: from.ID,
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	_, err = q.MoveFeedFilterRules(ctx, database.MoveFeedFilterRulesParams{
+		ToFeedID:   to.ID,
+		FromFeedID: from.ID,
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	_, err = q.MoveFeedWebhooks(ctx, database.MoveFeedWebhooksParams{
+		ToFeedID:   to.ID,
+		FromFeedID: from.ID,
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := q.DeleteFeed(ctx, from.ID); err != nil {
+		return 0, 0, err
+	}
+	return posts, follows, tx.Commit()
+}
+
+// getEditableFeed looks up a feed by URL and checks that user may change it.
+func getEditableFeed(s *State, user database.User, feedURL string) (database.Feed, error) {
+	feed, err := s.DB.GetFeedByURL(s.Ctx, feedURL)
+	if err == sql.ErrNoRows {
+		return feed, fmt.Errorf("Feed %s not found", feedURL)
+	}
+	if err != nil {
+		return feed, err
+	}
+	if !canEditFeed(user, feed) {
+		return feed, fmt.Errorf("Only the user who added %s or an admin can change it", feed.Name)
+	}
+	return feed, nil
+}
+
+// canEditFeed reports whether user may change a feed that every follower
+// shares: only its owner and admins can.
+func canEditFeed(user database.User, feed database.Feed) bool {
+	return feed.UserID == user.ID || user.Role == RoleAdmin
+}
@@ -0,0 +1,313 @@
+package commands
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/UUest/gator/internal/database"
+	"github.com/UUest/gator/internal/render"
+)
+
+// Filter rule actions. Hide and highlight change how browse shows a post,
+// while mark-read and star change the post's state for the rule's owner as
+// soon as agg stores it.
+const (
+	FilterHide      = "hide"
+	FilterHighlight = "highlight"
+	FilterMarkRead  = "mark-read"
+	FilterStar      = "star"
+)
+
+var filterActions = []string{FilterHide, FilterHighlight, FilterMarkRead, FilterStar}
+
+// filterRule is a stored rule with its title pattern compiled.
+type filterRule struct {
+	database.FilterRule
+	pattern *regexp.Regexp
+}
+
+// compileFilterRules compiles each rule's pattern. filter add rejects bad
+// patterns, so one that doesn't compile came from an edited archive; the rule
+// is logged and left out rather than failing every post it would be tried on.
+func compileFilterRules(log *slog.Logger, rules []database.FilterRule) []filterRule {
+	compiled := make([]filterRule, 0, len(rules))
+	for _, rule := range rules {
+		pattern, err := regexp.Compile(rule.TitlePattern)
+		if err != nil {
+			log.Warn("skipping filter rule with an invalid pattern", "rule_id", rule.ID, "pattern", rule.TitlePattern, "err", err)
+			continue
+		}
+		compiled = append(compiled, filterRule{FilterRule: rule, pattern: pattern})
+	}
+	return compiled
+}
+
+func (r filterRule) matches(feedID uuid.UUID, title string) bool {
+	return (!r.FeedID.Valid || r.FeedID.UUID == feedID) && r.pattern.MatchString(title)
+}
+
+// userFilterRules returns every rule user has added.
+func userFilterRules(s *State, user database.User) ([]filterRule, error) {
+	rows, err := s.DB.GetFilterRulesForUser(s.Ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	rules := make([]database.FilterRule, len(rows))
+	for i, row := range rows {
+		rules[i] = database.FilterRule{
+			ID:           row.ID,
+			CreatedAt:    row.CreatedAt,
+			UserID:       row.UserID,
+			FeedID:       row.FeedID,
+			TitlePattern: row.TitlePattern,
+			Action:       row.Action,
+		}
+	}
+	return compileFilterRules(s.Logger, rules), nil
+}
+
+// postFilter is what a user's hide and highlight rules say about a post.
+type postFilter struct {
+	hidden      bool
+	highlighted bool
+}
+
+func evaluateFilters(rules []filterRule, feedID uuid.UUID, title string) postFilter {
+	var pf postFilter
+	for _, rule := range rules {
+		if !rule.matches(feedID, title) {
+			continue
+		}
+		switch rule.Action {
+		case FilterHide:
+			pf.hidden = true
+		case FilterHighlight:
+			pf.highlighted = true
+		}
+	}
+	return pf
+}
+
+// applyIngestFilters carries out the mark-read and star rules that match a
+// post agg has just stored. rules may belong to any of the feed's followers.
+func applyIngestFilters(ctx context.Context, s *State, log *slog.Logger, rules []filterRule, feedID uuid.UUID, postID int32, title string) error {
+	for _, rule := range rules {
+		if !rule.matches(feedID, title) {
+			continue
+		}
+		var err error
+		switch rule.Action {
+		case FilterMarkRead:
+			err = s.DB.MarkPostRead(ctx, database.MarkPostReadParams{
+				UserID: rule.UserID,
+				PostID: postID,
+			})
+		case FilterStar:
+			err = s.DB.SetPostStarred(ctx, database.SetPostStarredParams{
+				UserID:  rule.UserID,
+				PostID:  postID,
+				Starred: true,
+			})
+		default:
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		log.Debug("filter rule applied", "rule_id", rule.ID, "action", rule.Action, "post_id", postID)
+	}
+	return nil
+}
+
+// HandlerFilter dispatches the "filter" subcommands, which manage the current
+// user's filter rules.
+func HandlerFilter(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) == 0 {
+		return fmt.Errorf("Expected a filter subcommand")
+	}
+	sub := Command{Name: cmd.Args[0], Args: cmd.Args[1:]}
+	switch sub.Name {
+	case "add":
+		return handlerFilterAdd(s, sub, user)
+	case "list":
+		return handlerFilterList(s, sub, user)
+	case "remove":
+		return handlerFilterRemove(s, sub, user)
+	case "test":
+		return handlerFilterTest(s, sub, user)
+	default:
+		return fmt.Errorf("Unknown filter subcommand: %s", sub.Name)
+	}
+}
+
+func handlerFilterAdd(s *State, cmd Command, user database.User) error {
+	flags := flag.NewFlagSet("filter add", flag.ContinueOnError)
+	feedURL := flags.String("feed", "", "only apply to this feed instead of every feed you follow")
+	titleMatch := flags.String("title-match", "", "regular expression matched against post titles")
+	action := flags.String("action", "", "hide, highlight, mark-read or star")
+	if err := flags.Parse(cmd.Args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 || *titleMatch == "" || *action == "" {
+		return fmt.Errorf("Usage: gator filter add [--feed <feed_url>] --title-match <regex> --action hide|highlight|mark-read|star")
+	}
+	if !slices.Contains(filterActions, *action) {
+		return fmt.Errorf("Unknown action %s, expected one of %s", *action, strings.Join(filterActions, ", "))
+	}
+	if _, err := regexp.Compile(*titleMatch); err != nil {
+		return fmt.Errorf("Invalid --title-match pattern: %w", err)
+	}
+	feedID, err := filterFeedID(s, *feedURL)
+	if err != nil {
+		return err
+	}
+	rule, err := s.DB.CreateFilterRule(s.Ctx, database.CreateFilterRuleParams{
+		UserID:       user.ID,
+		FeedID:       feedID,
+		TitlePattern: *titleMatch,
+		Action:       *action,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Added filter rule %d: %s\n", rule.ID, describeFilterRule(rule.TitlePattern, rule.Action, *feedURL))
+	return nil
+}
+
+func handlerFilterList(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) != 0 {
+		return fmt.Errorf("Usage: gator filter list")
+	}
+	rules, err := s.DB.GetFilterRulesForUser(s.Ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		fmt.Println("No filter rules")
+		return nil
+	}
+	fmt.Println("Filter rules:")
+	for _, rule := range rules {
+		fmt.Printf("%d: %s\n", rule.ID, describeFilterRule(rule.TitlePattern, rule.Action, rule.FeedUrl.String))
+	}
+	return nil
+}
+
+func handlerFilterRemove(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("Usage: gator filter remove <rule_id>")
+	}
+	id, err := strconv.ParseInt(cmd.Args[0], 10, 32)
+	if err != nil {
+		return fmt.Errorf("Invalid filter rule ID: %s", cmd.Args[0])
+	}
+	removed, err := s.DB.DeleteFilterRule(s.Ctx, database.DeleteFilterRuleParams{
+		ID:     int32(id),
+		UserID: user.ID,
+	})
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		return fmt.Errorf("Filter rule %d not found", id)
+	}
+	fmt.Printf("Filter rule %d removed\n", id)
+	return nil
+}
+
+// handlerFilterTest lists which of the user's recent posts a rule matches,
+// either a saved rule given by ID or a pattern that hasn't been added yet.
+func handlerFilterTest(s *State, cmd Command, user database.User) error {
+	flags := flag.NewFlagSet("filter test", flag.ContinueOnError)
+	feedURL := flags.String("feed", "", "only test posts from this feed")
+	titleMatch := flags.String("title-match", "", "regular expression to test instead of a saved rule")
+	limit := flags.Int("limit", 100, "how many recent posts to test against")
+	if err := flags.Parse(cmd.Args); err != nil {
+		return err
+	}
+	if (*titleMatch == "") == (flags.NArg() == 0) || flags.NArg() > 1 || *limit <= 0 {
+		return fmt.Errorf("Usage: gator filter test [--limit <n>] <rule_id> | gator filter test [--limit <n>] [--feed <feed_url>] --title-match <regex>")
+	}
+
+	var compiled filterRule
+	if *titleMatch != "" {
+		feedID, err := filterFeedID(s, *feedURL)
+		if err != nil {
+			return err
+		}
+		pattern, err := regexp.Compile(*titleMatch)
+		if err != nil {
+			return fmt.Errorf("Invalid --title-match pattern: %w", err)
+		}
+		compiled = filterRule{
+			FilterRule: database.FilterRule{FeedID: feedID, TitlePattern: *titleMatch},
+			pattern:    pattern,
+		}
+	} else {
+		if *feedURL != "" {
+			return fmt.Errorf("--feed can only be used with --title-match")
+		}
+		id, err := strconv.ParseInt(flags.Arg(0), 10, 32)
+		if err != nil {
+			return fmt.Errorf("Invalid filter rule ID: %s", flags.Arg(0))
+		}
+		rules, err := userFilterRules(s, user)
+		if err != nil {
+			return err
+		}
+		i := slices.IndexFunc(rules, func(r filterRule) bool { return r.ID == int32(id) })
+		if i < 0 {
+			return fmt.Errorf("Filter rule %d not found", id)
+		}
+		compiled = rules[i]
+	}
+
+	posts, err := s.DB.GetPostsForUser(s.Ctx, database.GetPostsForUserParams{
+		UserID: user.ID,
+		Limit:  int32(*limit),
+	})
+	if err != nil {
+		return err
+	}
+	matched := 0
+	for _, post := range posts {
+		if compiled.matches(post.FeedID, post.Title) {
+			matched++
+			fmt.Printf("%d: %s\n", post.ID, render.StripControl(post.Title))
+		}
+	}
+	fmt.Printf("Matched %d of %d recent posts\n", matched, len(posts))
+	return nil
+}
+
+// filterFeedID looks up the feed a rule or webhook is limited to, if any.
+func filterFeedID(s *State, feedURL string) (uuid.NullUUID, error) {
+	if feedURL == "" {
+		return uuid.NullUUID{}, nil
+	}
+	feed, err := s.DB.GetFeedByURL(s.Ctx, feedURL)
+	if err == sql.ErrNoRows {
+		return uuid.NullUUID{}, fmt.Errorf("Feed %s not found", feedURL)
+	}
+	if err != nil {
+		return uuid.NullUUID{}, err
+	}
+	return uuid.NullUUID{UUID: feed.ID, Valid: true}, nil
+}
+
+func describeFilterRule(pattern, action, feedURL string) string {
+	scope := "every followed feed"
+	if feedURL != "" {
+		scope = feedURL
+	}
+	return fmt.Sprintf("%s titles matching /%s/ in %s", action, pattern, scope)
+}
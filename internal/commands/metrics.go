@@ -0,0 +1,92 @@
+package commands
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/UUest/gator/internal/database"
+	"github.com/UUest/gator/internal/fetch"
+	"github.com/UUest/gator/internal/metrics"
+)
+
+// Metrics served by agg --metrics.
+var (
+	feedFetches = metrics.NewCounter("gator_feed_fetches_total",
+		`Feed fetches by HTTP status, or "error" when no response was received.`, "status")
+	feedFetchBytes = metrics.NewCounter("gator_feed_fetch_bytes_total",
+		"Bytes of feed bodies downloaded, after decompression.")
+	feedFetchDuration = metrics.NewHistogram("gator_feed_fetch_duration_seconds",
+		"Time taken to download a feed, by host.", metrics.DefaultBuckets, "host")
+	feedParseFailures = metrics.NewCounter("gator_feed_parse_failures_total",
+		`Feeds the strict XML parser rejected, by whether lenient parsing "recovered" them or also "failed".`, "result")
+	feedPosts = metrics.NewCounter("gator_feed_posts_total",
+		`Feed items seen while scraping, by whether they were "inserted", "updated" because the feed changed them, already "existing" unchanged or "pruned" before.`, "result")
+	feedsDue = metrics.NewGauge("gator_feeds_due",
+		"Followed feeds that have not been fetched within the agg interval.")
+	schedulerLag = metrics.NewGauge("gator_scheduler_lag_seconds",
+		"How long the least recently fetched feed has been waiting past the agg interval.")
+	webhookDeliveries = metrics.NewCounter("gator_webhook_deliveries_total",
+		`Webhook delivery attempts, by whether the post was "delivered", will be tried again ("retry") or was given up on ("failed").`, "result")
+)
+
+func observeFetch(feedURL string, started time.Time, resp *fetch.Response, err error) {
+	host := "unknown"
+	if u, parseErr := url.Parse(feedURL); parseErr == nil && u.Host != "" {
+		host = u.Host
+	}
+	feedFetchDuration.Observe(time.Since(started).Seconds(), host)
+
+	status := "error"
+	if code := fetchStatusCode(err); code != 0 {
+		status = strconv.Itoa(code)
+	}
+	feedFetches.Inc(status)
+	// Bodies are counted as soon as they are downloaded, so those FetchFeed
+	// then rejects with a ContentError are included.
+	if resp != nil {
+		feedFetchBytes.Add(float64(len(resp.Body)))
+	}
+}
+
+// observeBacklog updates the queue depth and scheduler lag gauges for user's
+// feeds, given how often agg fetches.
+func observeBacklog(s *State, user database.User, interval time.Duration) error {
+	backlog, err := s.DB.GetFetchBacklog(s.Ctx, database.GetFetchBacklogParams{
+		MinAgeSeconds: interval.Seconds(),
+		UserID:        user.ID,
+	})
+	if err != nil {
+		return err
+	}
+	feedsDue.Set(float64(backlog.DueFeeds))
+	schedulerLag.Set(max(backlog.OldestFetchAgeSeconds-interval.Seconds(), 0))
+	return nil
+}
+
+// serveMetrics serves /metrics on addr in the background. The returned
+// function stops the server.
+func serveMetrics(log *slog.Logger, addr string) (func(), error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != http.ErrServerClosed {
+			log.Error("metrics server stopped", "err", err)
+		}
+	}()
+	log.Info("serving metrics", "url", "http://"+ln.Addr().String()+"/metrics")
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}, nil
+}
@@ -0,0 +1,55 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/UUest/gator/internal/fetch"
+	"github.com/UUest/gator/internal/metrics"
+)
+
+// metricValue reads an unlabelled series from the metrics endpoint.
+func metricValue(t *testing.T, name string) float64 {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), name+" "); ok {
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return v
+		}
+	}
+	return 0
+}
+
+func TestFetchFeedCountsBytesOfUnreadableFeeds(t *testing.T) {
+	const body = "this is not a feed"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+	f, err := fetch.New(fetch.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := metricValue(t, "gator_feed_fetch_bytes_total")
+	_, err = FetchFeed(context.Background(), f, srv.URL)
+	var contentErr *ContentError
+	if !errors.As(err, &contentErr) {
+		t.Fatalf("FetchFeed error = %v, want a *ContentError", err)
+	}
+	if got := metricValue(t, "gator_feed_fetch_bytes_total") - before; got != float64(len(body)) {
+		t.Errorf("bytes counted = %v, want %d", got, len(body))
+	}
+}
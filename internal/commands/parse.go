@@ -0,0 +1,120 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// pubDateLayouts are the date formats found in pubDate elements. RSS asks for
+// RFC 822 dates, but feeds also use four-digit years, single-digit days and
+// the RFC 3339 dates of Atom.
+var pubDateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, _2 Jan 2006 15:04:05 -0700",
+	"Mon, _2 Jan 2006 15:04:05 MST",
+	"_2 Jan 2006 15:04:05 -0700",
+	"_2 Jan 2006 15:04:05 MST",
+	time.RFC822Z,
+	time.RFC822,
+	"Mon, _2 Jan 06 15:04:05 -0700",
+	"Mon, _2 Jan 06 15:04:05 MST",
+	time.RFC3339,
+}
+
+// parsePubDate parses an item's pubDate in any of pubDateLayouts.
+func parsePubDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, fmt.Errorf("no publication date")
+	}
+	for _, layout := range pubDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized publication date %q", value)
+}
+
+// feedAutoClose is xml.HTMLAutoClose without link, which is an empty element
+// in HTML but holds each item's URL in RSS.
+var feedAutoClose = func() []string {
+	var names []string
+	for _, name := range xml.HTMLAutoClose {
+		if name != "link" {
+			names = append(names, name)
+		}
+	}
+	return names
+}()
+
+// parseFeed decodes an RSS document that is already UTF-8. Feeds the strict
+// decoder rejects are sanitized and decoded again in lenient mode, which
+// tolerates bare ampersands, HTML entities such as &nbsp; and unclosed tags.
+// The strict decoder's error is then kept in the feed's ParseError.
+func parseFeed(data []byte) (*RSSFeed, error) {
+	var feed RSSFeed
+	strictErr := newFeedDecoder(data).Decode(&feed)
+	if strictErr == nil {
+		return &feed, nil
+	}
+
+	feed = RSSFeed{}
+	dec := newFeedDecoder(sanitizeXML(data))
+	dec.Strict = false
+	dec.AutoClose = feedAutoClose
+	dec.Entity = xml.HTMLEntity
+	if err := dec.Decode(&feed); err != nil {
+		return nil, fmt.Errorf("Could not parse feed: %w", strictErr)
+	}
+	feed.ParseError = strictErr
+	return &feed, nil
+}
+
+func newFeedDecoder(data []byte) *xml.Decoder {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	// The body was transcoded to UTF-8 when it was fetched, whatever the
+	// prolog still says.
+	dec.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	return dec
+}
+
+// sanitizeXML drops anything before the first tag, such as stray whitespace or
+// server warnings, and removes bytes and characters that XML 1.0 forbids
+// anywhere in a document: control characters, invalid UTF-8, byte order marks
+// and the noncharacters U+FFFE and U+FFFF.
+func sanitizeXML(data []byte) []byte {
+	if i := bytes.IndexByte(data, '<'); i > 0 {
+		data = data[i:]
+	}
+	out := make([]byte, 0, len(data))
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		if isXMLChar(r) && !(r == utf8.RuneError && size == 1) {
+			out = append(out, data[:size]...)
+		}
+		data = data[size:]
+	}
+	return out
+}
+
+func isXMLChar(r rune) bool {
+	switch {
+	case r == '\t' || r == '\n' || r == '\r':
+		return true
+	case r < 0x20:
+		return false
+	case r == 0xFEFF || r == 0xFFFE || r == 0xFFFF:
+		return false
+	case r >= 0xD800 && r <= 0xDFFF:
+		return false
+	}
+	return r <= utf8.MaxRune
+}
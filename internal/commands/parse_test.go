@@ -0,0 +1,135 @@
+package commands
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePubDate(t *testing.T) {
+	want := time.Date(2024, time.March, 5, 9, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"RFC 1123 with offset", "Tue, 05 Mar 2024 09:30:00 +0000"},
+		{"RFC 1123 with zone", "Tue, 05 Mar 2024 09:30:00 GMT"},
+		{"single-digit day", "Tue, 5 Mar 2024 09:30:00 +0000"},
+		{"no weekday", "5 Mar 2024 09:30:00 +0000"},
+		{"RFC 822", "05 Mar 24 09:30 +0000"},
+		{"two-digit year with seconds", "Tue, 05 Mar 24 09:30:00 +0000"},
+		{"RFC 3339", "2024-03-05T09:30:00Z"},
+		{"other offset", "Tue, 05 Mar 2024 10:30:00 +0100"},
+		{"surrounding whitespace", "\n  Tue, 05 Mar 2024 09:30:00 +0000\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePubDate(tt.value)
+			if err != nil {
+				t.Fatalf("parsePubDate(%q): %v", tt.value, err)
+			}
+			if !got.Equal(want) {
+				t.Errorf("parsePubDate(%q) = %v, want %v", tt.value, got, want)
+			}
+		})
+	}
+
+	for _, value := range []string{"", "yesterday", "2024-03-05"} {
+		if _, err := parsePubDate(value); err == nil {
+			t.Errorf("parsePubDate(%q) succeeded, want an error", value)
+		}
+	}
+}
+
+func TestSanitizeXML(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"valid", "<rss>\t<title>a\r\nb</title></rss>", "<rss>\t<title>a\r\nb</title></rss>"},
+		{"leading junk", "Warning: x\n<rss/>", "<rss/>"},
+		{"control characters", "<title>a\x00b\x0bc\x1bd</title>", "<title>abcd</title>"},
+		{"invalid UTF-8", "<title>a\xffb\xc3</title>", "<title>ab</title>"},
+		{"byte order mark", "\uFEFF<rss/>", "<rss/>"},
+		{"noncharacters", "<title>a\uFFFEb\uFFFFc</title>", "<title>abc</title>"},
+		{"keeps entities", "<title>a &amp; b &nbsp;</title>", "<title>a &amp; b &nbsp;</title>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(sanitizeXML([]byte(tt.data))); got != tt.want {
+				t.Errorf("sanitizeXML(%q) = %q, want %q", tt.data, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFeedLenient(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		title string
+		link  string
+	}{
+		{
+			name:  "bare ampersand",
+			data:  `<rss><channel><item><title>Q&A</title><link>https://example.com/?a=1&b=2</link></item></channel></rss>`,
+			title: "Q&A",
+			link:  "https://example.com/?a=1&b=2",
+		},
+		{
+			name:  "HTML entity",
+			data:  `<rss><channel><item><title>a&nbsp;b</title><link>https://example.com/</link></item></channel></rss>`,
+			title: "a\u00a0b",
+			link:  "https://example.com/",
+		},
+		{
+			name:  "control character",
+			data:  "<rss><channel><item><title>a\x0cb</title><link>https://example.com/</link></item></channel></rss>",
+			title: "ab",
+			link:  "https://example.com/",
+		},
+		{
+			name:  "unclosed tag",
+			data:  `<rss><channel><item><title>a</title><link>https://example.com/</link><description><br></description></item></channel></rss>`,
+			title: "a",
+			link:  "https://example.com/",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed, err := parseFeed([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("parseFeed: %v", err)
+			}
+			if feed.ParseError == nil {
+				t.Errorf("ParseError is nil, want the strict decoder's error")
+			}
+			if len(feed.Channel.Items) != 1 {
+				t.Fatalf("got %d items, want 1", len(feed.Channel.Items))
+			}
+			item := feed.Channel.Items[0]
+			if item.Title != tt.title || item.Link != tt.link {
+				t.Errorf("item = %q %q, want %q %q", item.Title, item.Link, tt.title, tt.link)
+			}
+		})
+	}
+}
+
+func TestParseFeed(t *testing.T) {
+	feed, err := parseFeed([]byte(`<?xml version="1.0" encoding="ISO-8859-1"?>
+<rss><channel><title>Blog</title><item><title>café</title><pubDate>Tue, 05 Mar 2024 09:30:00 +0000</pubDate></item></channel></rss>`))
+	if err != nil {
+		t.Fatalf("parseFeed: %v", err)
+	}
+	// The body is already UTF-8, whatever the prolog says.
+	if feed.ParseError != nil {
+		t.Errorf("ParseError = %v, want a strict parse", feed.ParseError)
+	}
+	if feed.Channel.Title != "Blog" || len(feed.Channel.Items) != 1 || feed.Channel.Items[0].Title != "café" {
+		t.Errorf("parsed %+v", feed.Channel)
+	}
+
+	if _, err := parseFeed([]byte("not a feed")); err == nil {
+		t.Errorf("parseFeed of plain text succeeded")
+	}
+}
@@ -0,0 +1,433 @@
+package commands
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/UUest/gator/internal/database"
+	"github.com/UUest/gator/internal/fetch"
+	"github.com/UUest/gator/internal/render"
+)
+
+const (
+	defaultPodcastLimit       = 20
+	defaultPodcastConcurrency = 3
+)
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// HandlerPodcasts dispatches the "podcasts" subcommands. Episodes are posts
+// with an audio or video enclosure, identified by the enclosure ID.
+func HandlerPodcasts(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) == 0 {
+		return handlerPodcastsList(s, cmd, user)
+	}
+	sub := Command{Name: cmd.Args[0], Args: cmd.Args[1:]}
+	switch sub.Name {
+	case "list":
+		return handlerPodcastsList(s, sub, user)
+	case "download":
+		return handlerPodcastsDownload(s, sub, user)
+	case "played":
+		return handlerPodcastsPlayed(s, sub, user)
+	default:
+		return fmt.Errorf("Unknown podcasts subcommand: %s", sub.Name)
+	}
+}
+
+func handlerPodcastsList(s *State, cmd Command, user database.User) error {
+	limit := int64(defaultPodcastLimit)
+	if len(cmd.Args) == 1 {
+		var err error
+		limit, err = strconv.ParseInt(cmd.Args[0], 10, 32)
+		if err != nil {
+			return err
+		}
+	}
+	episodes, err := s.DB.GetPodcastEpisodes(s.Ctx, database.GetPodcastEpisodesParams{
+		UserID: user.ID,
+		Limit:  int32(limit),
+	})
+	if err != nil {
+		return err
+	}
+	if len(episodes) == 0 {
+		fmt.Println("No podcast episodes found")
+		return nil
+	}
+	fmt.Println("Episodes:")
+	for _, ep := range episodes {
+		status := ""
+		if ep.DownloadedAt.Valid {
+			status += " [downloaded]"
+		}
+		if ep.PlayedAt.Valid {
+			status += " [played]"
+		}
+		fmt.Printf("%d: %s  %s - %s%s\n", ep.ID, ep.PublishedAt.Format("2006-01-02"), ep.FeedName, render.StripControl(ep.Title), status)
+	}
+	return nil
+}
+
+func handlerPodcastsDownload(s *State, cmd Command, user database.User) error {
+	flags := flag.NewFlagSet("podcasts download", flag.ContinueOnError)
+	concurrency := flags.Int("concurrency", defaultPodcastConcurrency, "number of simultaneous downloads")
+	limit := flags.Int("limit", defaultPodcastLimit, "how many recent episodes to consider when no IDs are given")
+	if err := flags.Parse(cmd.Args); err != nil {
+		return err
+	}
+	if *concurrency < 1 {
+		return fmt.Errorf("Concurrency must be at least 1")
+	}
+
+	var episodes []database.GetPodcastEpisodesRow
+	if flags.NArg() > 0 {
+		for _, arg := range flags.Args() {
+			id, err := strconv.ParseInt(arg, 10, 32)
+			if err != nil {
+				return fmt.Errorf("Invalid episode ID: %s", arg)
+			}
+			ep, err := s.DB.GetPodcastEpisode(s.Ctx, database.GetPodcastEpisodeParams{
+				UserID: user.ID,
+				ID:     int32(id),
+			})
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("Episode %d not found in your feeds", id)
+			}
+			if err != nil {
+				return err
+			}
+			episodes = append(episodes, database.GetPodcastEpisodesRow(ep))
+		}
+	} else {
+		recent, err := s.DB.GetPodcastEpisodes(s.Ctx, database.GetPodcastEpisodesParams{
+			UserID: user.ID,
+			Limit:  int32(*limit),
+		})
+		if err != nil {
+			return err
+		}
+		for _, ep := range recent {
+			if !ep.DownloadedAt.Valid {
+				episodes = append(episodes, ep)
+			}
+		}
+	}
+	if len(episodes) == 0 {
+		fmt.Println("Nothing to download")
+		return nil
+	}
+
+	dir := s.Config.PodcastDirectory()
+	sem := make(chan struct{}, *concurrency)
+	errs := make([]error, len(episodes))
+	var wg sync.WaitGroup
+	for i, ep := range episodes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			dest, reused, err := downloadEpisode(s.Ctx, s, user, dir, ep)
+			if err != nil {
+				errs[i] = err
+				s.Logger.Error("download failed", "user", user.Name, "episode_id", ep.ID, "title", ep.Title, "url", ep.Url, "err", err)
+				return
+			}
+			if reused {
+				fmt.Printf("Already have %d (%s): %s\n", ep.ID, render.StripControl(ep.Title), dest)
+			} else {
+				fmt.Printf("Downloaded %d (%s): %s\n", ep.ID, render.StripControl(ep.Title), dest)
+			}
+		}()
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d downloads failed", failed, len(episodes))
+	}
+	return nil
+}
+
+func handlerPodcastsPlayed(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("Usage: gator podcasts played <episode_id>")
+	}
+	id, err := strconv.ParseInt(cmd.Args[0], 10, 32)
+	if err != nil {
+		return fmt.Errorf("Invalid episode ID: %s", cmd.Args[0])
+	}
+	ep, err := s.DB.GetPodcastEpisode(s.Ctx, database.GetPodcastEpisodeParams{
+		UserID: user.ID,
+		ID:     int32(id),
+	})
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("Episode %d not found in your feeds", id)
+	}
+	if err != nil {
+		return err
+	}
+	err = s.DB.MarkEpisodePlayed(s.Ctx, database.MarkEpisodePlayedParams{
+		UserID:      user.ID,
+		EnclosureID: ep.ID,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Marked %s as played\n", render.StripControl(ep.Title))
+	return nil
+}
+
+// downloadEpisode stores the episode's enclosure under dir and records it for
+// user. A file already downloaded by anyone, either for the same enclosure or
+// with the same SHA-256, is reused instead of keeping a second copy. It
+// returns the file path and whether an existing file was reused.
+func downloadEpisode(ctx context.Context, s *State, user database.User, dir string, ep database.GetPodcastEpisodesRow) (string, bool, error) {
+	existing, err := s.DB.GetCompletedDownloadForEnclosure(ctx, ep.ID)
+	if err != nil && err != sql.ErrNoRows {
+		return "", false, err
+	}
+	if err == nil && fileMatches(existing.Path, existing.Size, existing.Sha256) {
+		return existing.Path, true, saveDownload(ctx, s, user, ep.ID, existing.Path, existing.Sha256, existing.Size)
+	}
+
+	dest := filepath.Join(dir, safeFileName(ep.FeedName), fmt.Sprintf("%d-%s", ep.ID, episodeFileName(ep.Url)))
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return "", false, err
+	}
+	// Each user resumes their own partial file, so two of them downloading
+	// the same episode at once don't write into each other's.
+	partPath := fmt.Sprintf("%s.%s.part", dest, user.ID)
+	if err := downloadFile(ctx, s.Fetcher, ep.Url, partPath); err != nil {
+		return "", false, err
+	}
+	sum, size, err := fileSHA256(partPath)
+	if err != nil {
+		return "", false, err
+	}
+
+	dup, err := s.DB.GetCompletedDownloadBySHA256(ctx, sum)
+	if err != nil && err != sql.ErrNoRows {
+		return "", false, err
+	}
+	if err == nil && dup.Path != dest && fileMatches(dup.Path, dup.Size, dup.Sha256) {
+		if err := os.Remove(partPath); err != nil {
+			return "", false, err
+		}
+		return dup.Path, true, saveDownload(ctx, s, user, ep.ID, dup.Path, sum, size)
+	}
+	if err := os.Rename(partPath, dest); err != nil {
+		return "", false, err
+	}
+	return dest, false, saveDownload(ctx, s, user, ep.ID, dest, sum, size)
+}
+
+func saveDownload(ctx context.Context, s *State, user database.User, enclosureID int32, path, sum string, size int64) error {
+	return s.DB.SavePodcastDownload(ctx, database.SavePodcastDownloadParams{
+		UserID:      user.ID,
+		EnclosureID: enclosureID,
+		Path:        path,
+		Sha256:      sum,
+		Size:        size,
+	})
+}
+
+// downloadFile fetches fileURL into partPath, resuming from the end of a
+// partial file left by an earlier attempt when the server supports ranges.
+// A partial file that doesn't fit what the server reports is started over.
+func downloadFile(ctx context.Context, fetcher *fetch.Fetcher, fileURL, partPath string) error {
+	err := downloadRange(ctx, fetcher, fileURL, partPath)
+	if err == errRestartDownload {
+		if err := os.Truncate(partPath, 0); err != nil {
+			return err
+		}
+		err = downloadRange(ctx, fetcher, fileURL, partPath)
+	}
+	return err
+}
+
+// errRestartDownload is returned by downloadRange when the partial file can't
+// be resumed and has to be downloaded again from the start.
+var errRestartDownload = errors.New("partial download can't be resumed")
+
+func downloadRange(ctx context.Context, fetcher *fetch.Fetcher, fileURL, partPath string) error {
+	f, err := os.OpenFile(partPath, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	offset := info.Size()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := fetcher.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	want := resp.ContentLength
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		start, _, _, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || start != offset {
+			return errRestartDownload
+		}
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+	case http.StatusOK:
+		if err := f.Truncate(0); err != nil {
+			return err
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The partial file already holds the whole episode, but only if it
+		// is exactly as long as the server says the episode is.
+		_, _, total, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || total != offset {
+			return errRestartDownload
+		}
+		return f.Close()
+	default:
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	written, err := io.Copy(f, resp.Body)
+	if err != nil {
+		return err
+	}
+	if want >= 0 && written != want {
+		return fmt.Errorf("download ended after %d of %d bytes", written, want)
+	}
+	return f.Close()
+}
+
+// parseContentRange parses a Content-Range header, either "bytes
+// start-end/total" or "bytes */total" for a 416 response, where start and end
+// are -1. total is -1 when the server doesn't know it.
+func parseContentRange(value string) (start, end, total int64, ok bool) {
+	spec, found := strings.CutPrefix(value, "bytes ")
+	if !found {
+		return 0, 0, 0, false
+	}
+	rng, size, found := strings.Cut(spec, "/")
+	if !found {
+		return 0, 0, 0, false
+	}
+	total = -1
+	if size != "*" {
+		n, err := strconv.ParseInt(size, 10, 64)
+		if err != nil || n < 0 {
+			return 0, 0, 0, false
+		}
+		total = n
+	}
+	if rng == "*" {
+		return -1, -1, total, true
+	}
+	first, last, found := strings.Cut(rng, "-")
+	if !found {
+		return 0, 0, 0, false
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, 0, false
+	}
+	end, err = strconv.ParseInt(last, 10, 64)
+	if err != nil || end < start || (total >= 0 && end >= total) {
+		return 0, 0, 0, false
+	}
+	return start, end, total, true
+}
+
+// removeUnusedDownloads deletes the downloaded files at paths that no
+// download record refers to any more. Downloads are shared between users and
+// episodes with the same content, so a file stays while anyone still has it.
+func removeUnusedDownloads(ctx context.Context, s *State, paths []string) {
+	for _, path := range paths {
+		inUse, err := s.DB.IsDownloadPathInUse(ctx, path)
+		if err != nil {
+			s.Logger.Warn("could not check whether a podcast download is still used", "path", path, "err", err)
+			continue
+		}
+		if inUse {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			s.Logger.Warn("could not delete podcast download", "path", path, "err", err)
+		}
+	}
+}
+
+func fileSHA256(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// fileMatches reports whether the file at path still exists with the recorded
+// size and checksum.
+func fileMatches(path string, size int64, sum string) bool {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() != size {
+		return false
+	}
+	actual, _, err := fileSHA256(path)
+	return err == nil && actual == sum
+}
+
+func episodeFileName(enclosureURL string) string {
+	name := "episode"
+	if u, err := url.Parse(enclosureURL); err == nil {
+		if base := path.Base(u.Path); base != "." && base != "/" {
+			name = base
+		}
+	}
+	return safeFileName(name)
+}
+
+func safeFileName(name string) string {
+	name = unsafeFileChars.ReplaceAllString(name, "_")
+	if name == "" || name == "." || name == ".." {
+		return "_"
+	}
+	return name
+}
@@ -0,0 +1,107 @@
+package commands
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/UUest/gator/internal/fetch"
+)
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		value             string
+		start, end, total int64
+		ok                bool
+	}{
+		{"bytes 0-499/1234", 0, 499, 1234, true},
+		{"bytes 500-1233/*", 500, 1233, -1, true},
+		{"bytes */1234", -1, -1, 1234, true},
+		{"bytes 500-1234/1234", 0, 0, 0, false},
+		{"bytes 9-3/10", 0, 0, 0, false},
+		{"items 0-1/2", 0, 0, 0, false},
+		{"bytes 0-1", 0, 0, 0, false},
+		{"", 0, 0, 0, false},
+	}
+	for _, tt := range tests {
+		start, end, total, ok := parseContentRange(tt.value)
+		if ok != tt.ok || (ok && (start != tt.start || end != tt.end || total != tt.total)) {
+			t.Errorf("parseContentRange(%q) = %d, %d, %d, %v, want %d, %d, %d, %v",
+				tt.value, start, end, total, ok, tt.start, tt.end, tt.total, tt.ok)
+		}
+	}
+}
+
+func TestDownloadFile(t *testing.T) {
+	episode := strings.Repeat("0123456789", 100)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/episode.mp3", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "episode.mp3", time.Time{}, strings.NewReader(episode))
+	})
+	// A server that answers every range request from the wrong offset.
+	mux.HandleFunc("/misaligned.mp3", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") == "" {
+			w.Write([]byte(episode))
+			return
+		}
+		w.Header().Set("Content-Range", "bytes 0-9/1000")
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte(episode[:10]))
+	})
+	mux.HandleFunc("/truncated.mp3", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1000")
+		w.Write([]byte(episode[:10]))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	fetcher, err := fetch.New(fetch.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		partial string
+		wantErr bool
+	}{
+		{"fresh", "/episode.mp3", "", false},
+		{"resumed", "/episode.mp3", episode[:300], false},
+		{"already complete", "/episode.mp3", episode, false},
+		{"partial file too long", "/episode.mp3", episode + "garbage", false},
+		{"range from the wrong offset", "/misaligned.mp3", episode[:300], false},
+		{"truncated response", "/truncated.mp3", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			partPath := filepath.Join(t.TempDir(), "episode.mp3.part")
+			if tt.partial != "" {
+				if err := os.WriteFile(partPath, []byte(tt.partial), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := downloadFile(context.Background(), fetcher, srv.URL+tt.path, partPath)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("downloadFile succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("downloadFile: %v", err)
+			}
+			got, err := os.ReadFile(partPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != episode {
+				t.Errorf("downloaded %d bytes, want the %d-byte episode", len(got), len(episode))
+			}
+		})
+	}
+}
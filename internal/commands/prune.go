@@ -0,0 +1,160 @@
+package commands
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/UUest/gator/internal/database"
+)
+
+// pruneInterval is how often agg applies the retention policies.
+const pruneInterval = time.Hour
+
+// fetchHistoryDays is how long feed fetch history is kept for feed inspect.
+const fetchHistoryDays = 90
+
+// prunedPostDays is how long the URL of a pruned post is remembered after agg
+// last saw it in its feed. By then the post has dropped out of the feed and
+// can't be stored again.
+const prunedPostDays = 30
+
+// HandlerPrune deletes the posts that the global and per-feed retention
+// policies no longer keep, together with old fetch history and webhook
+// deliveries. Starred posts are never deleted, and neither are posts a
+// follower hasn't read unless prune_unread is set. With --dry-run it only
+// reports what would go.
+func HandlerPrune(s *State, cmd Command, user database.User) error {
+	flags := flag.NewFlagSet("prune", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "list what would be deleted without deleting it")
+	if err := flags.Parse(cmd.Args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return fmt.Errorf("Usage: gator prune [--dry-run]")
+	}
+	pruned, err := prunePosts(s.Ctx, s, *dryRun)
+	if err != nil {
+		return err
+	}
+	verb := "Pruned"
+	if *dryRun {
+		verb = "Would prune"
+	}
+	var total int64
+	for _, row := range pruned {
+		fmt.Printf("%s %d posts from %s\n", verb, row.Posts, row.FeedName)
+		total += row.Posts
+	}
+	fmt.Printf("%s %d posts in total\n", verb, total)
+	if *dryRun {
+		return nil
+	}
+	fetches, err := s.DB.PruneFeedFetches(s.Ctx, fetchHistoryDays)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Pruned %d fetch history entries older than %d days\n", fetches, fetchHistoryDays)
+	expired, err := s.DB.ExpirePrunedPosts(s.Ctx, prunedPostDays)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Forgot %d pruned posts not seen in their feed for %d days\n", expired, prunedPostDays)
+	deliveries, err := s.DB.PruneWebhookDeliveries(s.Ctx, webhookHistoryDays)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Pruned %d webhook deliveries older than %d days\n", deliveries, webhookHistoryDays)
+	return nil
+}
+
+// prunePosts applies the retention policies and returns how many posts each
+// feed lost, or would lose when dryRun is set. Pruned post URLs are
+// remembered so that agg doesn't store them again while they are still in
+// the feed. A post starred after it was found prunable is kept. Downloaded
+// podcast episodes of pruned posts are deleted too.
+func prunePosts(ctx context.Context, s *State, dryRun bool) ([]database.PrunePostsRow, error) {
+	prunable, err := s.DB.GetPrunablePosts(ctx, database.GetPrunablePostsParams{
+		DefaultPosts:  int32(s.Config.RetentionPosts),
+		DefaultDays:   int32(s.Config.RetentionDays),
+		ProtectUnread: !s.Config.PruneUnread,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(prunable) == 0 {
+		return nil, nil
+	}
+	if !dryRun {
+		ids := make([]int32, len(prunable))
+		for i, post := range prunable {
+			ids[i] = post.ID
+		}
+		downloads, err := s.DB.GetDownloadPathsForPosts(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		rows, err := s.DB.PrunePosts(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		removeUnusedDownloads(ctx, s, downloads)
+		return rows, nil
+	}
+	// Posts come sorted by feed, so each feed's are together.
+	var rows []database.PrunePostsRow
+	for i, post := range prunable {
+		if i == 0 || prunable[i-1].FeedID != post.FeedID {
+			rows = append(rows, database.PrunePostsRow{FeedName: post.FeedName})
+		}
+		rows[len(rows)-1].Posts++
+	}
+	return rows, nil
+}
+
+// autoPrune is prune as run by agg, logging instead of printing.
+func autoPrune(ctx context.Context, s *State) error {
+	pruned, err := prunePosts(ctx, s, false)
+	if err != nil {
+		return err
+	}
+	for _, row := range pruned {
+		s.Logger.Info("pruned posts", "feed", row.FeedName, "posts", row.Posts)
+	}
+	fetches, err := s.DB.PruneFeedFetches(ctx, fetchHistoryDays)
+	if err != nil {
+		return err
+	}
+	if fetches > 0 {
+		s.Logger.Info("pruned fetch history", "entries", fetches, "older_than_days", fetchHistoryDays)
+	}
+	expired, err := s.DB.ExpirePrunedPosts(ctx, prunedPostDays)
+	if err != nil {
+		return err
+	}
+	if expired > 0 {
+		s.Logger.Info("forgot pruned posts", "posts", expired, "unseen_for_days", prunedPostDays)
+	}
+	deliveries, err := s.DB.PruneWebhookDeliveries(ctx, webhookHistoryDays)
+	if err != nil {
+		return err
+	}
+	if deliveries > 0 {
+		s.Logger.Info("pruned webhook deliveries", "deliveries", deliveries, "older_than_days", webhookHistoryDays)
+	}
+	return nil
+}
+
+// describeRetention explains a retention policy, where 0 means no limit.
+func describeRetention(posts, days int32) string {
+	switch {
+	case posts > 0 && days > 0:
+		return fmt.Sprintf("keep the newest %d posts, none older than %d days", posts, days)
+	case posts > 0:
+		return fmt.Sprintf("keep the newest %d posts", posts)
+	case days > 0:
+		return fmt.Sprintf("keep posts for %d days", days)
+	}
+	return "keep every post"
+}
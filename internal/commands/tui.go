@@ -0,0 +1,349 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+
+	"github.com/UUest/gator/internal/database"
+	"github.com/UUest/gator/internal/render"
+)
+
+const tuiPostLimit = 200
+
+const (
+	paneFeeds = iota
+	panePosts
+	panePreview
+)
+
+type tuiModel struct {
+	s             *State
+	user          database.User
+	feeds         []database.GetFollowedFeedsWithUnreadRow
+	posts         []database.GetFeedPostsForUserRow
+	feedIdx       int
+	postIdx       int
+	focus         int
+	previewScroll int
+	status        string
+}
+
+func HandlerTUI(s *State, cmd Command, user database.User) error {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return fmt.Errorf("tui requires an interactive terminal")
+	}
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, oldState)
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	defer fmt.Print("\x1b[?25h\x1b[?1049l")
+
+	m := &tuiModel{s: s, user: user}
+	if err := m.loadFeeds(); err != nil {
+		return err
+	}
+	// Raw mode turns Ctrl-C into a key press, but SIGTERM still cancels
+	// s.Ctx, and the terminal is restored before gator exits.
+	buf := make([]byte, 16)
+	for {
+		m.draw()
+		key, err := readInput(s.Ctx, func() (string, error) {
+			n, err := os.Stdin.Read(buf)
+			return string(buf[:n]), err
+		})
+		if s.Ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if quit := m.handleKey(key); quit {
+			return nil
+		}
+	}
+}
+
+func (m *tuiModel) loadFeeds() error {
+	feeds, err := m.s.DB.GetFollowedFeedsWithUnread(m.s.Ctx, m.user.ID)
+	if err != nil {
+		return err
+	}
+	m.feeds = feeds
+	if m.feedIdx >= len(m.feeds) {
+		m.feedIdx = max(len(m.feeds)-1, 0)
+	}
+	return m.loadPosts()
+}
+
+func (m *tuiModel) loadPosts() error {
+	m.posts = nil
+	if len(m.feeds) == 0 {
+		return nil
+	}
+	posts, err := m.s.DB.GetFeedPostsForUser(m.s.Ctx, database.GetFeedPostsForUserParams{
+		UserID: m.user.ID,
+		FeedID: m.feeds[m.feedIdx].ID,
+		Limit:  tuiPostLimit,
+	})
+	if err != nil {
+		return err
+	}
+	m.posts = posts
+	if m.postIdx >= len(m.posts) {
+		m.postIdx = max(len(m.posts)-1, 0)
+	}
+	m.previewScroll = 0
+	return nil
+}
+
+// handleKey applies a single keypress and reports whether the UI should exit.
+func (m *tuiModel) handleKey(key string) bool {
+	m.status = ""
+	var err error
+	switch key {
+	case "q", "\x03":
+		return true
+	case "\t", "l", "\x1b[C":
+		m.focus = min(m.focus+1, panePreview)
+	case "\x1b[Z", "h", "\x1b[D":
+		m.focus = max(m.focus-1, paneFeeds)
+	case "j", "\x1b[B":
+		err = m.move(1)
+	case "k", "\x1b[A":
+		err = m.move(-1)
+	case "\r", "\n":
+		if m.focus == paneFeeds {
+			m.focus = panePosts
+		} else if m.focus == panePosts && len(m.posts) > 0 {
+			m.focus = panePreview
+			err = m.setRead(true)
+		}
+	case "m":
+		if len(m.posts) > 0 {
+			err = m.setRead(!m.posts[m.postIdx].Read)
+		}
+	case "s":
+		err = m.toggleStar()
+	case "r":
+		err = m.loadFeeds()
+		if err == nil {
+			m.status = "Refreshed at " + time.Now().Format(time.Kitchen)
+		}
+	}
+	if err != nil {
+		m.status = "Error: " + err.Error()
+	}
+	return false
+}
+
+func (m *tuiModel) move(delta int) error {
+	switch m.focus {
+	case paneFeeds:
+		next := m.feedIdx + delta
+		if next < 0 || next >= len(m.feeds) {
+			return nil
+		}
+		m.feedIdx = next
+		m.postIdx = 0
+		return m.loadPosts()
+	case panePosts:
+		next := m.postIdx + delta
+		if next >= 0 && next < len(m.posts) {
+			m.postIdx = next
+			m.previewScroll = 0
+		}
+	case panePreview:
+		m.previewScroll = max(m.previewScroll+delta, 0)
+	}
+	return nil
+}
+
+func (m *tuiModel) setRead(read bool) error {
+	post := &m.posts[m.postIdx]
+	if post.Read == read {
+		return nil
+	}
+	var err error
+	if read {
+		err = m.s.DB.MarkPostRead(m.s.Ctx, database.MarkPostReadParams{
+			UserID: m.user.ID,
+			PostID: post.ID,
+		})
+	} else {
+		err = m.s.DB.MarkPostUnread(m.s.Ctx, database.MarkPostUnreadParams{
+			UserID: m.user.ID,
+			PostID: post.ID,
+		})
+	}
+	if err != nil {
+		return err
+	}
+	post.Read = read
+	if read {
+		m.feeds[m.feedIdx].Unread--
+	} else {
+		m.feeds[m.feedIdx].Unread++
+	}
+	return nil
+}
+
+func (m *tuiModel) toggleStar() error {
+	if len(m.posts) == 0 {
+		return nil
+	}
+	post := &m.posts[m.postIdx]
+	err := m.s.DB.SetPostStarred(m.s.Ctx, database.SetPostStarredParams{
+		UserID:  m.user.ID,
+		PostID:  post.ID,
+		Starred: !post.Starred,
+	})
+	if err != nil {
+		return err
+	}
+	post.Starred = !post.Starred
+	return nil
+}
+
+func (m *tuiModel) draw() {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width < 40 || height < 10 {
+		width, height = 80, 24
+	}
+	feedW := max(width/4, 20)
+	rightW := width - feedW - 1
+	bodyH := height - 1
+	postsH := bodyH / 2
+	previewH := bodyH - postsH - 1
+
+	left := m.feedLines(feedW, bodyH)
+	right := append(m.postLines(rightW, postsH), strings.Repeat("─", rightW))
+	right = append(right, m.previewLines(rightW, previewH)...)
+
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	for y := 0; y < bodyH; y++ {
+		b.WriteString(left[y])
+		b.WriteString("│")
+		b.WriteString(right[y])
+		b.WriteString("\r\n")
+	}
+	status := m.status
+	if status == "" {
+		status = "tab:pane  j/k:move  enter:open  m:read  s:star  r:refresh  q:quit"
+	}
+	b.WriteString("\x1b[7m" + fit(status, width) + "\x1b[0m")
+	fmt.Print(b.String())
+}
+
+func (m *tuiModel) feedLines(width, height int) []string {
+	lines := []string{m.header("Feeds", paneFeeds, width)}
+	start := scrollStart(m.feedIdx, height-1)
+	for i := start; i < len(m.feeds) && len(lines) < height; i++ {
+		f := m.feeds[i]
+		label := f.Name
+		if f.Unread > 0 {
+			label = fmt.Sprintf("%s (%d)", f.Name, f.Unread)
+		}
+		lines = append(lines, m.row(label, i == m.feedIdx, m.focus == paneFeeds, width))
+	}
+	return padLines(lines, width, height)
+}
+
+func (m *tuiModel) postLines(width, height int) []string {
+	lines := []string{m.header("Posts", panePosts, width)}
+	start := scrollStart(m.postIdx, height-1)
+	for i := start; i < len(m.posts) && len(lines) < height; i++ {
+		p := m.posts[i]
+		marker := " "
+		if !p.Read {
+			marker = "●"
+		}
+		star := " "
+		if p.Starred {
+			star = "★"
+		}
+		label := fmt.Sprintf("%s%s %s  %s", marker, star, p.PublishedAt.Format("2006-01-02"), p.Title)
+		lines = append(lines, m.row(label, i == m.postIdx, m.focus == panePosts, width))
+	}
+	return padLines(lines, width, height)
+}
+
+func (m *tuiModel) previewLines(width, height int) []string {
+	lines := []string{m.header("Preview", panePreview, width)}
+	if len(m.posts) == 0 {
+		return padLines(lines, width, height)
+	}
+	p := m.posts[m.postIdx]
+	body := []string{p.Title, p.Url, p.PublishedAt.Format(time.RFC1123)}
+	if p.Author != "" {
+		body = append(body, "By "+p.Author)
+	}
+	if len(p.Categories) > 0 {
+		body = append(body, "Tags: "+strings.Join(p.Categories, ", "))
+	}
+	body = append(body, "")
+	body = append(body, strings.Split(render.Terminal(postBody(p.Description, p.Content), width), "\n")...)
+	m.previewScroll = min(m.previewScroll, max(len(body)-1, 0))
+	for _, line := range body[m.previewScroll:] {
+		if len(lines) >= height {
+			break
+		}
+		lines = append(lines, fit(line, width))
+	}
+	return padLines(lines, width, height)
+}
+
+func (m *tuiModel) header(title string, pane, width int) string {
+	if m.focus == pane {
+		return "\x1b[1;4m" + fit(title, width) + "\x1b[0m"
+	}
+	return "\x1b[1m" + fit(title, width) + "\x1b[0m"
+}
+
+func (m *tuiModel) row(label string, selected, focused bool, width int) string {
+	text := fit(label, width)
+	switch {
+	case selected && focused:
+		return "\x1b[7m" + text + "\x1b[0m"
+	case selected:
+		return "\x1b[1m" + text + "\x1b[0m"
+	}
+	return text
+}
+
+// scrollStart returns the first visible index so that selected stays on screen.
+func scrollStart(selected, visible int) int {
+	if visible < 1 || selected < visible {
+		return 0
+	}
+	return selected - visible + 1
+}
+
+// fit pads or truncates s to exactly width runes. Line breaks and tabs would
+// break up the panes, so they become spaces, and other control characters
+// are dropped.
+func fit(s string, width int) string {
+	s = strings.NewReplacer("\n", " ", "\t", " ").Replace(render.StripControl(s))
+	r := []rune(s)
+	if len(r) > width {
+		if width > 1 {
+			return string(r[:width-1]) + "…"
+		}
+		return string(r[:width])
+	}
+	return s + strings.Repeat(" ", width-len(r))
+}
+
+func padLines(lines []string, width, height int) []string {
+	for len(lines) < height {
+		lines = append(lines, strings.Repeat(" ", width))
+	}
+	return lines
+}
@@ -0,0 +1,276 @@
+package commands
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/UUest/gator/internal/database"
+)
+
+// HandlerUser dispatches the "user" subcommands that manage accounts.
+func HandlerUser(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) == 0 {
+		return fmt.Errorf("Expected a user subcommand")
+	}
+	sub := Command{Name: cmd.Args[0], Args: cmd.Args[1:]}
+	switch sub.Name {
+	case "show":
+		return handlerUserShow(s, sub, user)
+	case "rename":
+		return handlerUserRename(s, sub, user)
+	case "delete":
+		return MiddlewareAdmin(handlerUserDelete)(s, sub)
+	case "role":
+		return MiddlewareAdmin(handlerUserRole)(s, sub)
+	case "set-password":
+		return MiddlewareAdmin(handlerUserSetPassword)(s, sub)
+	default:
+		return fmt.Errorf("Unknown user subcommand: %s", sub.Name)
+	}
+}
+
+func handlerUserShow(s *State, cmd Command, user database.User) error {
+	target := user
+	if len(cmd.Args) > 0 {
+		var err error
+		target, err = s.DB.GetUser(s.Ctx, cmd.Args[0])
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("User %s does not exist", cmd.Args[0])
+		}
+		if err != nil {
+			return err
+		}
+	}
+	stats, err := s.DB.GetUserStats(s.Ctx, target.ID)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Name: %s\n", target.Name)
+	if user.Role == RoleAdmin || target.ID == user.ID {
+		fmt.Printf("ID: %s\n", target.ID)
+	}
+	fmt.Printf("Role: %s\n", target.Role)
+	fmt.Printf("Registered: %s\n", target.CreatedAt.Format(time.RFC1123))
+	fmt.Printf("Feeds added: %d (followed by %d other users)\n", stats.FeedsAdded, stats.OtherFollowers)
+	fmt.Printf("Feeds followed: %d\n", stats.FeedsFollowed)
+	fmt.Printf("Posts read: %d\n", stats.PostsRead)
+	fmt.Printf("Posts starred: %d\n", stats.PostsStarred)
+	return nil
+}
+
+func handlerUserRename(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) != 2 {
+		return fmt.Errorf("Usage: gator user rename <username> <new_username>")
+	}
+	target, err := s.DB.GetUser(s.Ctx, cmd.Args[0])
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("User %s does not exist", cmd.Args[0])
+	}
+	if err != nil {
+		return err
+	}
+	if target.ID != user.ID && user.Role != RoleAdmin {
+		return fmt.Errorf("Only admins can rename other users")
+	}
+	newName := cmd.Args[1]
+	if _, err := s.DB.GetUser(s.Ctx, newName); err == nil {
+		return fmt.Errorf("User %s already exists", newName)
+	} else if err != sql.ErrNoRows {
+		return err
+	}
+	err = s.DB.RenameUser(s.Ctx, database.RenameUserParams{
+		ID:   target.ID,
+		Name: newName,
+	})
+	if err != nil {
+		return err
+	}
+	if target.ID == user.ID {
+		if err := s.Config.SetUser(newName); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("User %s renamed to %s\n", target.Name, newName)
+	return nil
+}
+
+// handlerUserDelete removes a user. Deleting a user cascades to the feeds they
+// added, which would take everyone else's subscriptions to those feeds with
+// it, so a user who added feeds can only be deleted after choosing to either
+// transfer those feeds to someone else or delete them explicitly.
+func handlerUserDelete(s *State, cmd Command, user database.User) error {
+	flags := flag.NewFlagSet("user delete", flag.ContinueOnError)
+	transferTo := flags.String("transfer-to", "", "give the user's feeds to this user first")
+	deleteFeeds := flags.Bool("delete-feeds", false, "delete the user's feeds along with them")
+	yes := flags.Bool("yes", false, "skip the confirmation prompt")
+	if err := flags.Parse(cmd.Args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 || (*transferTo != "" && *deleteFeeds) {
+		return fmt.Errorf("Usage: gator user delete [--transfer-to <username> | --delete-feeds] [--yes] <username>")
+	}
+
+	ctx := s.Ctx
+	target, err := s.DB.GetUser(ctx, flags.Arg(0))
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("User %s does not exist", flags.Arg(0))
+	}
+	if err != nil {
+		return err
+	}
+	if target.Role == RoleAdmin {
+		admins, err := s.DB.CountAdmins(ctx)
+		if err != nil {
+			return err
+		}
+		if admins <= 1 {
+			return fmt.Errorf("Cannot delete the last admin")
+		}
+	}
+	stats, err := s.DB.GetUserStats(ctx, target.ID)
+	if err != nil {
+		return err
+	}
+
+	var newOwner database.User
+	description := fmt.Sprintf("user %s", target.Name)
+	if stats.FeedsAdded > 0 {
+		switch {
+		case *transferTo != "":
+			newOwner, err = s.DB.GetUser(ctx, *transferTo)
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("User %s does not exist", *transferTo)
+			}
+			if err != nil {
+				return err
+			}
+			if newOwner.ID == target.ID {
+				return fmt.Errorf("Cannot transfer feeds to the user being deleted")
+			}
+			description += fmt.Sprintf(" after giving their %d feeds to %s", stats.FeedsAdded, newOwner.Name)
+		case *deleteFeeds:
+			description += fmt.Sprintf(" and the %d feeds they added, unsubscribing %d other users", stats.FeedsAdded, stats.OtherFollowers)
+		default:
+			return fmt.Errorf("%s added %d feeds followed by %d other users; use --transfer-to <username> to hand them over or --delete-feeds to delete them",
+				target.Name, stats.FeedsAdded, stats.OtherFollowers)
+		}
+	}
+
+	if !*yes {
+		ok, err := confirm(s.Ctx, fmt.Sprintf("This will permanently delete %s.", description))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Println("Delete cancelled")
+			return nil
+		}
+	}
+	path, err := writeSnapshot(s, "user-delete")
+	if err != nil {
+		return fmt.Errorf("Could not write backup, nothing was deleted: %w", err)
+	}
+	fmt.Printf("Backup written to %s\n", path)
+
+	tx, err := s.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	q := s.DB.WithTx(tx)
+	if newOwner.ID != uuid.Nil {
+		moved, err := q.TransferFeedOwnership(ctx, database.TransferFeedOwnershipParams{
+			ToUserID:   newOwner.ID,
+			FromUserID: target.ID,
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Transferred %d feeds to %s\n", moved, newOwner.Name)
+	}
+	if err := q.DeleteUser(ctx, target.ID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if target.ID == user.ID {
+		if err := s.Config.SetSession("", ""); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("User %s deleted\n", target.Name)
+	return nil
+}
+
+func handlerUserRole(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) != 2 || (cmd.Args[1] != RoleAdmin && cmd.Args[1] != RoleMember) {
+		return fmt.Errorf("Usage: gator user role <username> admin|member")
+	}
+	target, err := s.DB.GetUser(s.Ctx, cmd.Args[0])
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("User %s does not exist", cmd.Args[0])
+	}
+	if err != nil {
+		return err
+	}
+	if target.Role == RoleAdmin && cmd.Args[1] == RoleMember {
+		admins, err := s.DB.CountAdmins(s.Ctx)
+		if err != nil {
+			return err
+		}
+		if admins <= 1 {
+			return fmt.Errorf("Cannot demote the last admin")
+		}
+	}
+	err = s.DB.SetUserRole(s.Ctx, database.SetUserRoleParams{
+		ID:   target.ID,
+		Role: cmd.Args[1],
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("User %s is now %s\n", target.Name, cmd.Args[1])
+	return nil
+}
+
+// handlerUserSetPassword lets an admin choose a new password for any user,
+// including users from before passwords existed, who can't log in until they
+// have one. The user's sessions are signed out.
+func handlerUserSetPassword(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("Usage: gator user set-password <username>")
+	}
+	target, err := s.DB.GetUser(s.Ctx, cmd.Args[0])
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("User %s does not exist", cmd.Args[0])
+	}
+	if err != nil {
+		return err
+	}
+	hash, err := readNewPassword(s.Ctx)
+	if err != nil {
+		return err
+	}
+	err = s.DB.SetUserPassword(s.Ctx, database.SetUserPasswordParams{
+		ID:           target.ID,
+		PasswordHash: hash,
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.DB.DeleteSessionsForUser(s.Ctx, target.ID); err != nil {
+		return err
+	}
+	if target.ID == user.ID {
+		if err := startSession(s, user); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Password for %s set\n", target.Name)
+	return nil
+}
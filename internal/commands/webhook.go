@@ -0,0 +1,422 @@
+package commands
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/UUest/gator/internal/database"
+	"github.com/UUest/gator/internal/fetch"
+	"github.com/UUest/gator/internal/render"
+	"github.com/UUest/gator/internal/webhook"
+)
+
+// Webhook delivery states.
+const (
+	webhookPending   = "pending"
+	webhookDelivered = "delivered"
+	webhookFailed    = "failed"
+)
+
+const (
+	// webhookPollInterval is how often agg looks for deliveries that are due
+	// to be retried between scrapes.
+	webhookPollInterval = 15 * time.Second
+	// webhookBatch is how many deliveries are claimed at a time.
+	webhookBatch = 50
+	// webhookLease is how long a claimed delivery is left alone by other agg
+	// processes. It is retried after that if its attempt was never recorded.
+	webhookLease = 2 * webhook.Timeout
+	// webhookMaxAttempts is how many times a delivery is tried before it is
+	// marked failed. Retries back off exponentially from webhookBackoff, so
+	// the last one happens about an hour after the first.
+	webhookMaxAttempts = 8
+	webhookBackoff     = 30 * time.Second
+	// webhookHistoryDays is how long finished deliveries are kept for
+	// webhook log. Retries are over within hours, so the log only has to
+	// cover recent problems.
+	webhookHistoryDays = 30
+)
+
+// deliverWebhooks sends every webhook delivery that is due, first attempts
+// and retries alike, and records how each attempt went.
+func deliverWebhooks(ctx context.Context, s *State) error {
+	for {
+		claimed, err := s.DB.ClaimWebhookDeliveries(ctx, database.ClaimWebhookDeliveriesParams{
+			LeaseSeconds:  webhookLease.Seconds(),
+			MaxDeliveries: webhookBatch,
+		})
+		if err != nil {
+			return err
+		}
+		for _, delivery := range claimed {
+			// Deliveries claimed but not sent are retried once their
+			// lease runs out.
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := deliverWebhook(ctx, s, delivery); err != nil {
+				return err
+			}
+		}
+		if len(claimed) < webhookBatch {
+			return nil
+		}
+	}
+}
+
+func deliverWebhook(ctx context.Context, s *State, delivery database.ClaimWebhookDeliveriesRow) error {
+	log := s.Logger.With("webhook_id", delivery.WebhookID, "delivery_id", delivery.ID, "post_id", delivery.PostID)
+	status, err := webhook.Send(ctx, s.Fetcher, delivery.WebhookUrl, delivery.Secret, webhook.Payload{
+		Event:      webhook.EventPostCreated,
+		DeliveryID: delivery.ID,
+		WebhookID:  delivery.WebhookID,
+		SentAt:     time.Now().UTC(),
+		Feed: webhook.Feed{
+			ID:   delivery.FeedID,
+			Name: delivery.FeedName,
+			URL:  delivery.FeedUrl,
+		},
+		Post: webhook.Post{
+			ID:          delivery.PostID,
+			Title:       delivery.Title,
+			URL:         delivery.Url,
+			Description: delivery.Description,
+			Author:      delivery.Author,
+			PublishedAt: delivery.PublishedAt,
+		},
+	})
+	attempts := delivery.Attempts + 1
+	attempt := database.RecordWebhookAttemptParams{
+		ID:     delivery.ID,
+		Status: webhookDelivered,
+	}
+	if status != 0 {
+		attempt.StatusCode = sql.NullInt32{Int32: int32(status), Valid: true}
+	}
+	next, delay := webhookOutcome(attempts, status, err)
+	switch next {
+	case webhookDelivered:
+		webhookDeliveries.Inc(webhookDelivered)
+		log.Debug("webhook delivered", "status", status, "attempts", attempts)
+	case webhookFailed:
+		attempt.Status = webhookFailed
+		attempt.Error = err.Error()
+		webhookDeliveries.Inc(webhookFailed)
+		log.Warn("webhook delivery failed, giving up", "attempts", attempts, "err", err,
+			"retry", fmt.Sprintf("gator webhook retry %d", delivery.WebhookID))
+	default:
+		attempt.Status = webhookPending
+		attempt.RetryAfterSeconds = delay.Seconds()
+		attempt.Error = err.Error()
+		webhookDeliveries.Inc("retry")
+		log.Info("webhook delivery failed, will retry", "attempts", attempts, "retry_in", delay, "err", err)
+	}
+	// The attempt is recorded even when ctx was cancelled mid-send, so the
+	// delivery is retried instead of waiting for its lease to run out.
+	return s.DB.RecordWebhookAttempt(context.WithoutCancel(ctx), attempt)
+}
+
+// webhookOutcome returns the state a delivery moves to after its attempts-th
+// attempt got status and err, and for a retry how long to wait first. A
+// receiver that answers 410 Gone isn't tried again.
+func webhookOutcome(attempts int32, status int, err error) (string, time.Duration) {
+	switch {
+	case err == nil:
+		return webhookDelivered, 0
+	case status == http.StatusGone || attempts >= webhookMaxAttempts:
+		return webhookFailed, 0
+	}
+	return webhookPending, webhookRetryDelay(attempts, err)
+}
+
+// webhookRetryDelay is how long to wait after a delivery's attempts-th failed
+// attempt: webhookBackoff doubled for every earlier attempt, or longer if the
+// receiver asked for it with Retry-After.
+func webhookRetryDelay(attempts int32, err error) time.Duration {
+	delay := webhookBackoff << (attempts - 1)
+	var statusErr *fetch.StatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > delay {
+		delay = statusErr.RetryAfter
+	}
+	return delay
+}
+
+// sendDueWebhooks runs deliverWebhooks for agg, which keeps going when it
+// fails, so the error is only logged.
+func sendDueWebhooks(s *State) {
+	ctx, cancel := graceContext(s.Ctx, shutdownGrace)
+	defer cancel()
+	if err := deliverWebhooks(ctx, s); err != nil && s.Ctx.Err() == nil {
+		s.Logger.Error("could not deliver webhooks", "err", err)
+	}
+}
+
+// HandlerWebhook dispatches the "webhook" subcommands, which manage the
+// current user's webhooks.
+func HandlerWebhook(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) == 0 {
+		return fmt.Errorf("Expected a webhook subcommand")
+	}
+	sub := Command{Name: cmd.Args[0], Args: cmd.Args[1:]}
+	switch sub.Name {
+	case "add":
+		return handlerWebhookAdd(s, sub, user)
+	case "list":
+		return handlerWebhookList(s, sub, user)
+	case "remove":
+		return handlerWebhookRemove(s, sub, user)
+	case "log":
+		return handlerWebhookLog(s, sub, user)
+	case "retry":
+		return handlerWebhookRetry(s, sub, user)
+	case "test":
+		return handlerWebhookTest(s, sub, user)
+	default:
+		return fmt.Errorf("Unknown webhook subcommand: %s", sub.Name)
+	}
+}
+
+func handlerWebhookAdd(s *State, cmd Command, user database.User) error {
+	flags := flag.NewFlagSet("webhook add", flag.ContinueOnError)
+	feedURL := flags.String("feed", "", "only send posts from this feed instead of every feed you follow")
+	secret := flags.String("secret", "", "key to sign payloads with, generated when not given")
+	if err := flags.Parse(cmd.Args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("Usage: gator webhook add [--feed <feed_url>] [--secret <secret>] <url>")
+	}
+	target := flags.Arg(0)
+	if err := checkWebhookURL(target); err != nil {
+		return err
+	}
+	feedID, err := filterFeedID(s, *feedURL)
+	if err != nil {
+		return err
+	}
+	if feedID.Valid {
+		following, err := s.DB.IsFollowingFeed(s.Ctx, database.IsFollowingFeedParams{
+			FeedID: feedID.UUID,
+			UserID: user.ID,
+		})
+		if err != nil {
+			return err
+		}
+		if !following {
+			return fmt.Errorf("You do not follow %s; follow it before adding a webhook for it", *feedURL)
+		}
+	}
+	if *secret == "" {
+		raw := make([]byte, 32)
+		if _, err := rand.Read(raw); err != nil {
+			return err
+		}
+		*secret = hex.EncodeToString(raw)
+	}
+	hook, err := s.DB.CreateWebhook(s.Ctx, database.CreateWebhookParams{
+		UserID: user.ID,
+		FeedID: feedID,
+		Url:    target,
+		Secret: *secret,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Added webhook %d: %s\n", hook.ID, describeWebhook(hook.Url, *feedURL))
+	fmt.Printf("Secret: %s\n", hook.Secret)
+	fmt.Printf("Payloads are signed with it in the %s header\n", webhook.SignatureHeader)
+	return nil
+}
+
+func handlerWebhookList(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) != 0 {
+		return fmt.Errorf("Usage: gator webhook list")
+	}
+	hooks, err := s.DB.GetWebhooksForUser(s.Ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if len(hooks) == 0 {
+		fmt.Println("No webhooks")
+		return nil
+	}
+	fmt.Println("Webhooks:")
+	for _, hook := range hooks {
+		fmt.Printf("%d: %s (%d delivered, %d pending, %d failed)\n",
+			hook.ID, describeWebhook(hook.Url, hook.FeedUrl.String), hook.Delivered, hook.Pending, hook.Failed)
+	}
+	return nil
+}
+
+func handlerWebhookRemove(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("Usage: gator webhook remove <webhook_id>")
+	}
+	id, err := parseWebhookID(cmd.Args[0])
+	if err != nil {
+		return err
+	}
+	removed, err := s.DB.DeleteWebhook(s.Ctx, database.DeleteWebhookParams{
+		ID:     id,
+		UserID: user.ID,
+	})
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		return fmt.Errorf("Webhook %d not found", id)
+	}
+	fmt.Printf("Webhook %d removed\n", id)
+	return nil
+}
+
+// handlerWebhookLog lists a webhook's most recent deliveries and how their
+// last attempt went.
+func handlerWebhookLog(s *State, cmd Command, user database.User) error {
+	flags := flag.NewFlagSet("webhook log", flag.ContinueOnError)
+	limit := flags.Int("limit", 20, "how many recent deliveries to list")
+	if err := flags.Parse(cmd.Args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 || *limit <= 0 {
+		return fmt.Errorf("Usage: gator webhook log [--limit <n>] <webhook_id>")
+	}
+	hook, err := userWebhook(s, user, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	deliveries, err := s.DB.GetWebhookDeliveries(s.Ctx, database.GetWebhookDeliveriesParams{
+		WebhookID: hook.ID,
+		Limit:     int32(*limit),
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Webhook %d: %s\n", hook.ID, describeWebhook(hook.Url, hook.FeedUrl.String))
+	if len(deliveries) == 0 {
+		fmt.Println("No deliveries yet")
+		return nil
+	}
+	fmt.Println()
+	for _, d := range deliveries {
+		status := "-"
+		if d.StatusCode.Valid {
+			status = fmt.Sprint(d.StatusCode.Int32)
+		}
+		fmt.Printf("%s  %-9s  %3s  %d attempts  %s\n", d.CreatedAt.Format(time.DateTime), d.Status, status, d.Attempts, render.StripControl(d.PostTitle))
+		if d.Status == webhookPending && d.NextAttemptAt.Valid {
+			fmt.Printf("    Next Attempt: %s\n", d.NextAttemptAt.Time.Format(time.DateTime))
+		}
+		if d.Error != "" {
+			fmt.Printf("    Error: %s\n", render.StripControl(d.Error))
+		}
+	}
+	return nil
+}
+
+// handlerWebhookRetry queues a webhook's failed deliveries to be tried again
+// from scratch.
+func handlerWebhookRetry(s *State, cmd Command, user database.User) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("Usage: gator webhook retry <webhook_id>")
+	}
+	hook, err := userWebhook(s, user, cmd.Args[0])
+	if err != nil {
+		return err
+	}
+	queued, err := s.DB.RetryWebhookDeliveries(s.Ctx, hook.ID)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Queued %d failed deliveries to be sent again by agg\n", queued)
+	return nil
+}
+
+// handlerWebhookTest sends a test payload straight away, either to a saved
+// webhook or to a URL that hasn't been added yet, and reports the response.
+// Test deliveries are not retried or logged.
+func handlerWebhookTest(s *State, cmd Command, user database.User) error {
+	flags := flag.NewFlagSet("webhook test", flag.ContinueOnError)
+	target := flags.String("url", "", "URL to test instead of a saved webhook")
+	secret := flags.String("secret", "", "with --url, key to sign the payload with")
+	if err := flags.Parse(cmd.Args); err != nil {
+		return err
+	}
+	if (*target == "") == (flags.NArg() == 0) || flags.NArg() > 1 {
+		return fmt.Errorf("Usage: gator webhook test <webhook_id> | gator webhook test --url <url> [--secret <secret>]")
+	}
+	var webhookID int32
+	if *target == "" {
+		if *secret != "" {
+			return fmt.Errorf("--secret can only be used with --url")
+		}
+		hook, err := userWebhook(s, user, flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		webhookID, *target, *secret = hook.ID, hook.Url, hook.Secret
+	} else if err := checkWebhookURL(*target); err != nil {
+		return err
+	}
+
+	started := time.Now()
+	status, err := webhook.Send(s.Ctx, s.Fetcher, *target, *secret, webhook.TestPayload(webhookID))
+	elapsed := time.Since(started).Round(time.Millisecond)
+	if err != nil {
+		return fmt.Errorf("Test delivery to %s failed after %s: %w", *target, elapsed, err)
+	}
+	fmt.Printf("Test delivery to %s succeeded: status %d in %s\n", *target, status, elapsed)
+	return nil
+}
+
+// userWebhook looks up one of user's webhooks by the ID given on the command
+// line.
+func userWebhook(s *State, user database.User, arg string) (database.GetWebhooksForUserRow, error) {
+	id, err := parseWebhookID(arg)
+	if err != nil {
+		return database.GetWebhooksForUserRow{}, err
+	}
+	hooks, err := s.DB.GetWebhooksForUser(s.Ctx, user.ID)
+	if err != nil {
+		return database.GetWebhooksForUserRow{}, err
+	}
+	for _, hook := range hooks {
+		if hook.ID == id {
+			return hook, nil
+		}
+	}
+	return database.GetWebhooksForUserRow{}, fmt.Errorf("Webhook %d not found", id)
+}
+
+func parseWebhookID(arg string) (int32, error) {
+	id, err := strconv.ParseInt(arg, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid webhook ID: %s", arg)
+	}
+	return int32(id), nil
+}
+
+func checkWebhookURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("Invalid webhook URL %s, expected an http or https URL", rawURL)
+	}
+	return nil
+}
+
+func describeWebhook(hookURL, feedURL string) string {
+	scope := "every followed feed"
+	if feedURL != "" {
+		scope = feedURL
+	}
+	return fmt.Sprintf("POST new posts from %s to %s", scope, hookURL)
+}
@@ -0,0 +1,48 @@
+package commands
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/UUest/gator/internal/fetch"
+)
+
+func TestWebhookOutcome(t *testing.T) {
+	failed := errors.New("connection refused")
+	tests := []struct {
+		name      string
+		attempts  int32
+		status    int
+		err       error
+		want      string
+		wantDelay time.Duration
+	}{
+		{"delivered", 1, http.StatusOK, nil, webhookDelivered, 0},
+		{"first failure", 1, 0, failed, webhookPending, 30 * time.Second},
+		{"second failure", 2, http.StatusInternalServerError, &fetch.StatusError{StatusCode: 500}, webhookPending, time.Minute},
+		{"next to last failure", webhookMaxAttempts - 1, 0, failed, webhookPending, 32 * time.Minute},
+		{"last failure", webhookMaxAttempts, 0, failed, webhookFailed, 0},
+		{"gone", 1, http.StatusGone, &fetch.StatusError{StatusCode: http.StatusGone}, webhookFailed, 0},
+		{"longer Retry-After", 1, http.StatusTooManyRequests, &fetch.StatusError{StatusCode: 429, RetryAfter: 5 * time.Minute}, webhookPending, 5 * time.Minute},
+		{"shorter Retry-After", 3, http.StatusServiceUnavailable, &fetch.StatusError{StatusCode: 503, RetryAfter: time.Second}, webhookPending, 2 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, delay := webhookOutcome(tt.attempts, tt.status, tt.err)
+			if got != tt.want || delay != tt.wantDelay {
+				t.Errorf("webhookOutcome(%d, %d, %v) = %s, %s, want %s, %s", tt.attempts, tt.status, tt.err, got, delay, tt.want, tt.wantDelay)
+			}
+		})
+	}
+
+	// The README promises retries for about an hour.
+	var total time.Duration
+	for attempts := int32(1); attempts < webhookMaxAttempts; attempts++ {
+		total += webhookRetryDelay(attempts, failed)
+	}
+	if total < 55*time.Minute || total > 70*time.Minute {
+		t.Errorf("retries span %s, want about an hour", total)
+	}
+}
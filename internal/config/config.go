@@ -2,16 +2,45 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/UUest/gator/internal/fetch"
 )
 
 type Config struct {
 	DbURL           string `json:"db_url"`
 	CurrentUserName string `json:"current_user_name"`
+	SessionToken    string `json:"session_token,omitempty"`
+	PodcastDir      string `json:"podcast_dir,omitempty"`
+	BackupDir       string `json:"backup_dir,omitempty"`
+	FetchTimeout    string `json:"fetch_timeout,omitempty"`
+	FetchMaxBytes   int64  `json:"fetch_max_bytes,omitempty"`
+	Contact         string `json:"contact,omitempty"`
+	Proxy           string `json:"proxy,omitempty"`
+	LogLevel        string `json:"log_level,omitempty"`
+	LogFormat       string `json:"log_format,omitempty"`
+	// RetentionPosts and RetentionDays are the default post retention for
+	// feeds without their own; 0 keeps everything.
+	RetentionPosts int `json:"retention_posts,omitempty"`
+	RetentionDays  int `json:"retention_days,omitempty"`
+	// PruneUnread lets pruning delete posts that a follower hasn't read yet.
+	PruneUnread bool `json:"prune_unread,omitempty"`
 }
 
 const configFileName = ".gatorconfig.json"
 
+const (
+	defaultPodcastDir = "gator-podcasts"
+	defaultBackupDir  = "gator-backups"
+)
+
 func getConfigFilePath() string {
 	configFilePath, err := os.UserHomeDir()
 	if err != nil {
@@ -33,12 +62,20 @@ func Read() (*Config, error) {
 	return &config, nil
 }
 
+// Write saves config to the config file. The file holds the session token, so
+// only its owner may read it. WriteFile keeps the mode of an existing file,
+// so one from before sessions existed is made private before the token is
+// written to it.
 func Write(config Config) error {
 	configJSON, err := json.MarshalIndent(config, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(getConfigFilePath(), configJSON, 0644)
+	path := getConfigFilePath()
+	if err := os.Chmod(path, 0600); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return os.WriteFile(path, configJSON, 0600)
 }
 
 func (config *Config) SetUser(username string) error {
@@ -49,3 +86,73 @@ func (config *Config) SetUser(username string) error {
 	}
 	return nil
 }
+
+// SetSession records the logged in user together with the session token that
+// proves it.
+func (config *Config) SetSession(username, token string) error {
+	config.CurrentUserName = username
+	config.SessionToken = token
+	return Write(*config)
+}
+
+// PodcastDirectory returns where podcast episodes are downloaded to. Unless
+// podcast_dir is set it is a gator-podcasts directory in the user's home.
+func (config *Config) PodcastDirectory() string {
+	return homeSubdir(config.PodcastDir, defaultPodcastDir)
+}
+
+// BackupDirectory returns where automatic backups are written. Unless
+// backup_dir is set it is a gator-backups directory in the user's home.
+func (config *Config) BackupDirectory() string {
+	return homeSubdir(config.BackupDir, defaultBackupDir)
+}
+
+// FetchOptions returns the settings for outgoing HTTP requests. Unset fields
+// fall back to the fetch package defaults.
+func (config *Config) FetchOptions() (fetch.Options, error) {
+	opts := fetch.Options{
+		MaxBodySize: config.FetchMaxBytes,
+		Contact:     config.Contact,
+		Proxy:       config.Proxy,
+	}
+	if config.FetchTimeout != "" {
+		timeout, err := time.ParseDuration(config.FetchTimeout)
+		if err != nil {
+			return opts, fmt.Errorf("invalid fetch_timeout: %w", err)
+		}
+		opts.Timeout = timeout
+	}
+	return opts, nil
+}
+
+// Logger returns a logger writing to w at log_level (debug, info, warn or
+// error; info by default) in log_format (text by default, or json).
+func (config *Config) Logger(w io.Writer) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: slog.LevelInfo}
+	if config.LogLevel != "" {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(config.LogLevel)); err != nil {
+			return nil, fmt.Errorf("invalid log_level: %w", err)
+		}
+		opts.Level = level
+	}
+	switch config.LogFormat {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("invalid log_format %q, must be text or json", config.LogFormat)
+	}
+}
+
+func homeSubdir(configured, fallback string) string {
+	if configured != "" {
+		return configured
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		panic(err)
+	}
+	return filepath.Join(homeDir, fallback)
+}
@@ -7,6 +7,7 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
@@ -22,7 +23,7 @@ $4,
 $5,
 $6
 )
-RETURNING id, created_at, updated_at, name, url, user_id, last_fetched
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched, readability, redirect_url, redirect_count, retired_at, locked_until, retention_posts, retention_days
 `
 
 type AddFeedParams struct {
@@ -52,10 +53,112 @@ func (q *Queries) AddFeed(ctx context.Context, arg AddFeedParams) (Feed, error)
 		&i.Url,
 		&i.UserID,
 		&i.LastFetched,
+		&i.Readability,
+		&i.RedirectUrl,
+		&i.RedirectCount,
+		&i.RetiredAt,
+		&i.LockedUntil,
+		&i.RetentionPosts,
+		&i.RetentionDays,
 	)
 	return i, err
 }
 
+const claimFeed = `-- name: ClaimFeed :one
+UPDATE feeds
+SET last_fetched = NOW(),
+    locked_until = NOW() + make_interval(secs => $1::float8)
+WHERE id = $2
+  AND (locked_until IS NULL OR locked_until < NOW())
+  AND (last_fetched IS NULL OR last_fetched < NOW() - make_interval(secs => $3::float8))
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched, readability, redirect_url, redirect_count, retired_at, locked_until, retention_posts, retention_days
+`
+
+type ClaimFeedParams struct {
+	LeaseSeconds  float64
+	ID            uuid.UUID
+	MinAgeSeconds float64
+}
+
+func (q *Queries) ClaimFeed(ctx context.Context, arg ClaimFeedParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, claimFeed, arg.LeaseSeconds, arg.ID, arg.MinAgeSeconds)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetched,
+		&i.Readability,
+		&i.RedirectUrl,
+		&i.RedirectCount,
+		&i.RetiredAt,
+		&i.LockedUntil,
+		&i.RetentionPosts,
+		&i.RetentionDays,
+	)
+	return i, err
+}
+
+const claimNextFeedToFetch = `-- name: ClaimNextFeedToFetch :one
+UPDATE feeds
+SET last_fetched = NOW(),
+    locked_until = NOW() + make_interval(secs => $1::float8)
+WHERE id = (
+    SELECT f.id
+    FROM feeds f
+    JOIN feed_follows ff ON f.id = ff.feed_id
+    WHERE ff.user_id = $2
+      AND f.retired_at IS NULL
+      AND (f.locked_until IS NULL OR f.locked_until < NOW())
+    ORDER BY f.last_fetched ASC NULLS FIRST
+    LIMIT 1
+    FOR UPDATE OF f SKIP LOCKED
+)
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched, readability, redirect_url, redirect_count, retired_at, locked_until, retention_posts, retention_days
+`
+
+type ClaimNextFeedToFetchParams struct {
+	LeaseSeconds float64
+	UserID       uuid.UUID
+}
+
+func (q *Queries) ClaimNextFeedToFetch(ctx context.Context, arg ClaimNextFeedToFetchParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, claimNextFeedToFetch, arg.LeaseSeconds, arg.UserID)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetched,
+		&i.Readability,
+		&i.RedirectUrl,
+		&i.RedirectCount,
+		&i.RetiredAt,
+		&i.LockedUntil,
+		&i.RetentionPosts,
+		&i.RetentionDays,
+	)
+	return i, err
+}
+
+const clearFeedRedirect = `-- name: ClearFeedRedirect :exec
+UPDATE feeds
+SET redirect_url = NULL,
+    redirect_count = 0
+WHERE id = $1 AND redirect_count > 0
+`
+
+func (q *Queries) ClearFeedRedirect(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, clearFeedRedirect, id)
+	return err
+}
+
 const createFeedFollow = `-- name: CreateFeedFollow :one
 WITH inserted_feed_follow AS (
 INSERT INTO feed_follows (feed_id, user_id)
@@ -104,8 +207,88 @@ func (q *Queries) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowPara
 	return i, err
 }
 
+const deferFeed = `-- name: DeferFeed :exec
+UPDATE feeds
+SET locked_until = NOW() + make_interval(secs => $1::float8)
+WHERE id = $2
+`
+
+type DeferFeedParams struct {
+	DelaySeconds float64
+	ID           uuid.UUID
+}
+
+func (q *Queries) DeferFeed(ctx context.Context, arg DeferFeedParams) error {
+	_, err := q.db.ExecContext(ctx, deferFeed, arg.DelaySeconds, arg.ID)
+	return err
+}
+
+const deleteFeed = `-- name: DeleteFeed :exec
+DELETE FROM feeds
+WHERE id = $1
+`
+
+func (q *Queries) DeleteFeed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteFeed, id)
+	return err
+}
+
+const getDueFeeds = `-- name: GetDueFeeds :many
+SELECT f.id, f.created_at, f.updated_at, f.name, f.url, f.user_id, f.last_fetched, f.readability, f.redirect_url, f.redirect_count, f.retired_at, f.locked_until, f.retention_posts, f.retention_days
+FROM feeds f
+JOIN feed_follows ff ON f.id = ff.feed_id
+WHERE ff.user_id = $1
+  AND f.retired_at IS NULL
+  AND (f.locked_until IS NULL OR f.locked_until < NOW())
+  AND (f.last_fetched IS NULL OR f.last_fetched < NOW() - make_interval(secs => $2::float8))
+ORDER BY f.last_fetched ASC NULLS FIRST
+`
+
+type GetDueFeedsParams struct {
+	UserID        uuid.UUID
+	MinAgeSeconds float64
+}
+
+func (q *Queries) GetDueFeeds(ctx context.Context, arg GetDueFeedsParams) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, getDueFeeds, arg.UserID, arg.MinAgeSeconds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetched,
+			&i.Readability,
+			&i.RedirectUrl,
+			&i.RedirectCount,
+			&i.RetiredAt,
+			&i.LockedUntil,
+			&i.RetentionPosts,
+			&i.RetentionDays,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getFeedByURL = `-- name: GetFeedByURL :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched, readability, redirect_url, redirect_count, retired_at, locked_until, retention_posts, retention_days
 FROM feeds
 WHERE url = $1
 `
@@ -121,6 +304,13 @@ func (q *Queries) GetFeedByURL(ctx context.Context, url string) (Feed, error) {
 		&i.Url,
 		&i.UserID,
 		&i.LastFetched,
+		&i.Readability,
+		&i.RedirectUrl,
+		&i.RedirectCount,
+		&i.RetiredAt,
+		&i.LockedUntil,
+		&i.RetentionPosts,
+		&i.RetentionDays,
 	)
 	return i, err
 }
@@ -182,7 +372,7 @@ func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) (
 }
 
 const getFeeds = `-- name: GetFeeds :many
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched, readability, redirect_url, redirect_count, retired_at, locked_until, retention_posts, retention_days
 FROM feeds
 `
 
@@ -203,6 +393,13 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 			&i.Url,
 			&i.UserID,
 			&i.LastFetched,
+			&i.Readability,
+			&i.RedirectUrl,
+			&i.RedirectCount,
+			&i.RetiredAt,
+			&i.LockedUntil,
+			&i.RetentionPosts,
+			&i.RetentionDays,
 		); err != nil {
 			return nil, err
 		}
@@ -217,17 +414,114 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 	return items, nil
 }
 
-const getNextFeedToFetch = `-- name: GetNextFeedToFetch :one
-SELECT f.id, f.created_at, f.updated_at, f.name, f.url, f.user_id, f.last_fetched
+const getFetchBacklog = `-- name: GetFetchBacklog :one
+SELECT
+    COUNT(*) FILTER (
+        WHERE f.last_fetched IS NULL
+           OR f.last_fetched < NOW() - make_interval(secs => $1::float8)
+    ) AS due_feeds,
+    COALESCE(MAX(EXTRACT(EPOCH FROM NOW() - f.last_fetched)), 0)::float8 AS oldest_fetch_age_seconds
 FROM feeds f
 JOIN feed_follows ff ON f.id = ff.feed_id
-WHERE ff.user_id = $1
-ORDER BY f.last_fetched ASC NULLS FIRST
-LIMIT 1
+WHERE ff.user_id = $2 AND f.retired_at IS NULL
 `
 
-func (q *Queries) GetNextFeedToFetch(ctx context.Context, userID uuid.UUID) (Feed, error) {
-	row := q.db.QueryRowContext(ctx, getNextFeedToFetch, userID)
+type GetFetchBacklogParams struct {
+	MinAgeSeconds float64
+	UserID        uuid.UUID
+}
+
+type GetFetchBacklogRow struct {
+	DueFeeds              int64
+	OldestFetchAgeSeconds float64
+}
+
+func (q *Queries) GetFetchBacklog(ctx context.Context, arg GetFetchBacklogParams) (GetFetchBacklogRow, error) {
+	row := q.db.QueryRowContext(ctx, getFetchBacklog, arg.MinAgeSeconds, arg.UserID)
+	var i GetFetchBacklogRow
+	err := row.Scan(
+		&i.DueFeeds,
+		&i.OldestFetchAgeSeconds,
+	)
+	return i, err
+}
+
+const isFollowingFeed = `-- name: IsFollowingFeed :one
+SELECT EXISTS (
+  SELECT 1
+  FROM feed_follows
+  WHERE feed_id = $1 AND user_id = $2
+)
+`
+
+type IsFollowingFeedParams struct {
+	FeedID uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) IsFollowingFeed(ctx context.Context, arg IsFollowingFeedParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isFollowingFeed, arg.FeedID, arg.UserID)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const moveFeedFollows = `-- name: MoveFeedFollows :execrows
+INSERT INTO feed_follows (user_id, feed_id)
+SELECT ff.user_id, $1::uuid
+FROM feed_follows ff
+WHERE ff.feed_id = $2
+ON CONFLICT (user_id, feed_id) DO NOTHING
+`
+
+type MoveFeedFollowsParams struct {
+	ToFeedID   uuid.UUID
+	FromFeedID uuid.UUID
+}
+
+func (q *Queries) MoveFeedFollows(ctx context.Context, arg MoveFeedFollowsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, moveFeedFollows, arg.ToFeedID, arg.FromFeedID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const moveFeedPosts = `-- name: MoveFeedPosts :execrows
+UPDATE posts
+SET feed_id = $1,
+    updated_at = NOW()
+WHERE feed_id = $2
+`
+
+type MoveFeedPostsParams struct {
+	ToFeedID   uuid.UUID
+	FromFeedID uuid.UUID
+}
+
+func (q *Queries) MoveFeedPosts(ctx context.Context, arg MoveFeedPostsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, moveFeedPosts, arg.ToFeedID, arg.FromFeedID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const recordFeedRedirect = `-- name: RecordFeedRedirect :one
+UPDATE feeds
+SET redirect_count = CASE WHEN redirect_url = $2 THEN redirect_count + 1 ELSE 1 END,
+    redirect_url = $2
+WHERE id = $1
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched, readability, redirect_url, redirect_count, retired_at, locked_until, retention_posts, retention_days
+`
+
+type RecordFeedRedirectParams struct {
+	ID          uuid.UUID
+	RedirectUrl sql.NullString
+}
+
+func (q *Queries) RecordFeedRedirect(ctx context.Context, arg RecordFeedRedirectParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, recordFeedRedirect, arg.ID, arg.RedirectUrl)
 	var i Feed
 	err := row.Scan(
 		&i.ID,
@@ -237,20 +531,43 @@ func (q *Queries) GetNextFeedToFetch(ctx context.Context, userID uuid.UUID) (Fee
 		&i.Url,
 		&i.UserID,
 		&i.LastFetched,
+		&i.Readability,
+		&i.RedirectUrl,
+		&i.RedirectCount,
+		&i.RetiredAt,
+		&i.LockedUntil,
+		&i.RetentionPosts,
+		&i.RetentionDays,
 	)
 	return i, err
 }
 
-const markFeedFetched = `-- name: MarkFeedFetched :one
+const releaseFeed = `-- name: ReleaseFeed :exec
 UPDATE feeds
-SET last_fetched = NOW(),
+SET locked_until = NULL
+WHERE id = $1
+`
+
+func (q *Queries) ReleaseFeed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, releaseFeed, id)
+	return err
+}
+
+const renameFeed = `-- name: RenameFeed :one
+UPDATE feeds
+SET name = $2,
     updated_at = NOW()
 WHERE id = $1
-RETURNING id, created_at, updated_at, name, url, user_id, last_fetched
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched, readability, redirect_url, redirect_count, retired_at, locked_until, retention_posts, retention_days
 `
 
-func (q *Queries) MarkFeedFetched(ctx context.Context, id uuid.UUID) (Feed, error) {
-	row := q.db.QueryRowContext(ctx, markFeedFetched, id)
+type RenameFeedParams struct {
+	ID   uuid.UUID
+	Name string
+}
+
+func (q *Queries) RenameFeed(ctx context.Context, arg RenameFeedParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, renameFeed, arg.ID, arg.Name)
 	var i Feed
 	err := row.Scan(
 		&i.ID,
@@ -260,10 +577,171 @@ func (q *Queries) MarkFeedFetched(ctx context.Context, id uuid.UUID) (Feed, erro
 		&i.Url,
 		&i.UserID,
 		&i.LastFetched,
+		&i.Readability,
+		&i.RedirectUrl,
+		&i.RedirectCount,
+		&i.RetiredAt,
+		&i.LockedUntil,
+		&i.RetentionPosts,
+		&i.RetentionDays,
 	)
 	return i, err
 }
 
+const retireFeed = `-- name: RetireFeed :exec
+UPDATE feeds
+SET retired_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) RetireFeed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, retireFeed, id)
+	return err
+}
+
+const reviveFeed = `-- name: ReviveFeed :exec
+UPDATE feeds
+SET retired_at = NULL,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) ReviveFeed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, reviveFeed, id)
+	return err
+}
+
+const setFeedReadability = `-- name: SetFeedReadability :one
+UPDATE feeds
+SET readability = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched, readability, redirect_url, redirect_count, retired_at, locked_until, retention_posts, retention_days
+`
+
+type SetFeedReadabilityParams struct {
+	ID          uuid.UUID
+	Readability bool
+}
+
+func (q *Queries) SetFeedReadability(ctx context.Context, arg SetFeedReadabilityParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, setFeedReadability, arg.ID, arg.Readability)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetched,
+		&i.Readability,
+		&i.RedirectUrl,
+		&i.RedirectCount,
+		&i.RetiredAt,
+		&i.LockedUntil,
+		&i.RetentionPosts,
+		&i.RetentionDays,
+	)
+	return i, err
+}
+
+const setFeedRetention = `-- name: SetFeedRetention :one
+UPDATE feeds
+SET retention_posts = $2,
+    retention_days = $3,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched, readability, redirect_url, redirect_count, retired_at, locked_until, retention_posts, retention_days
+`
+
+type SetFeedRetentionParams struct {
+	ID             uuid.UUID
+	RetentionPosts sql.NullInt32
+	RetentionDays  sql.NullInt32
+}
+
+func (q *Queries) SetFeedRetention(ctx context.Context, arg SetFeedRetentionParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, setFeedRetention, arg.ID, arg.RetentionPosts, arg.RetentionDays)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetched,
+		&i.Readability,
+		&i.RedirectUrl,
+		&i.RedirectCount,
+		&i.RetiredAt,
+		&i.LockedUntil,
+		&i.RetentionPosts,
+		&i.RetentionDays,
+	)
+	return i, err
+}
+
+const setFeedURL = `-- name: SetFeedURL :one
+UPDATE feeds
+SET url = $2,
+    redirect_url = NULL,
+    redirect_count = 0,
+    retired_at = NULL,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched, readability, redirect_url, redirect_count, retired_at, locked_until, retention_posts, retention_days
+`
+
+type SetFeedURLParams struct {
+	ID  uuid.UUID
+	Url string
+}
+
+func (q *Queries) SetFeedURL(ctx context.Context, arg SetFeedURLParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, setFeedURL, arg.ID, arg.Url)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetched,
+		&i.Readability,
+		&i.RedirectUrl,
+		&i.RedirectCount,
+		&i.RetiredAt,
+		&i.LockedUntil,
+		&i.RetentionPosts,
+		&i.RetentionDays,
+	)
+	return i, err
+}
+
+const transferFeedOwnership = `-- name: TransferFeedOwnership :execrows
+UPDATE feeds
+SET user_id = $1,
+    updated_at = NOW()
+WHERE user_id = $2
+`
+
+type TransferFeedOwnershipParams struct {
+	ToUserID   uuid.UUID
+	FromUserID uuid.UUID
+}
+
+func (q *Queries) TransferFeedOwnership(ctx context.Context, arg TransferFeedOwnershipParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, transferFeedOwnership, arg.ToUserID, arg.FromUserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const unfollowFeed = `-- name: UnfollowFeed :one
 DELETE FROM feed_follows
 WHERE feed_id = $1 AND user_id = $2
@@ -11,14 +11,41 @@ import (
 	"github.com/google/uuid"
 )
 
+type Enclosure struct {
+	ID       int32
+	PostID   int32
+	Url      string
+	MimeType string
+	Length   int64
+}
+
 type Feed struct {
-	ID          uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Name        string
-	Url         string
-	UserID      uuid.UUID
-	LastFetched sql.NullTime
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Name           string
+	Url            string
+	UserID         uuid.UUID
+	LastFetched    sql.NullTime
+	Readability    bool
+	RedirectUrl    sql.NullString
+	RedirectCount  int32
+	RetiredAt      sql.NullTime
+	LockedUntil    sql.NullTime
+	RetentionPosts sql.NullInt32
+	RetentionDays  sql.NullInt32
+}
+
+type FeedFetch struct {
+	ID         int64
+	FeedID     uuid.UUID
+	FetchedAt  time.Time
+	DurationMs int32
+	StatusCode sql.NullInt32
+	Bytes      int64
+	ItemsSeen  int32
+	NewPosts   int32
+	Error      string
 }
 
 type FeedFollow struct {
@@ -29,6 +56,25 @@ type FeedFollow struct {
 	FeedID    uuid.UUID
 }
 
+type FilterRule struct {
+	ID           int32
+	CreatedAt    time.Time
+	UserID       uuid.UUID
+	FeedID       uuid.NullUUID
+	TitlePattern string
+	Action       string
+}
+
+type PodcastDownload struct {
+	UserID       uuid.UUID
+	EnclosureID  int32
+	Path         string
+	Sha256       string
+	Size         int64
+	DownloadedAt sql.NullTime
+	PlayedAt     sql.NullTime
+}
+
 type Post struct {
 	ID          int32
 	CreatedAt   time.Time
@@ -38,11 +84,76 @@ type Post struct {
 	Description string
 	PublishedAt time.Time
 	FeedID      uuid.UUID
+	Content     string
+	Author      string
+	Categories  []string
+	CommentsUrl string
 }
 
-type User struct {
-	ID        uuid.UUID
-	CreatedAt time.Time
+type PostState struct {
+	UserID    uuid.UUID
+	PostID    int32
+	ReadAt    sql.NullTime
+	Starred   bool
 	UpdatedAt time.Time
+}
+
+type PrunedPost struct {
+	Url        string
+	FeedID     uuid.UUID
+	PrunedAt   time.Time
+	LastSeenAt time.Time
+}
+
+type SavedSearch struct {
+	ID        int32
+	CreatedAt time.Time
+	UserID    uuid.UUID
 	Name      string
+	Query     string
+}
+
+type SearchMatch struct {
+	SearchID  int32
+	PostID    int32
+	MatchedAt time.Time
+	SeenAt    sql.NullTime
+}
+
+type Session struct {
+	TokenHash string
+	UserID    uuid.UUID
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+type User struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Name         string
+	PasswordHash string
+	Role         string
+}
+
+type Webhook struct {
+	ID        int32
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	FeedID    uuid.NullUUID
+	Url       string
+	Secret    string
+}
+
+type WebhookDelivery struct {
+	ID            int64
+	CreatedAt     time.Time
+	WebhookID     int32
+	PostID        int32
+	Status        string
+	Attempts      int32
+	NextAttemptAt sql.NullTime
+	LastAttemptAt sql.NullTime
+	StatusCode    sql.NullInt32
+	Error         string
 }
@@ -10,9 +10,10 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
-const createPost = `-- name: CreatePost :exec
+const createPost = `-- name: CreatePost :one
 INSERT INTO posts (
     created_at,
     updated_at,
@@ -20,7 +21,11 @@ INSERT INTO posts (
     url,
     description,
     published_at,
-    feed_id
+    feed_id,
+    content,
+    author,
+    categories,
+    comments_url
 ) VALUES (
     NOW(),
     NOW(),
@@ -28,8 +33,14 @@ INSERT INTO posts (
     $2,
     $3,
     $4,
-    $5
+    $5,
+    $6,
+    $7,
+    $8,
+    $9
 )
+ON CONFLICT (url) DO NOTHING
+RETURNING id
 `
 
 type CreatePostParams struct {
@@ -38,21 +49,121 @@ type CreatePostParams struct {
 	Description string
 	PublishedAt time.Time
 	FeedID      uuid.UUID
+	Content     string
+	Author      string
+	Categories  []string
+	CommentsUrl string
 }
 
-func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) error {
-	_, err := q.db.ExecContext(ctx, createPost,
+func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (int32, error) {
+	row := q.db.QueryRowContext(ctx, createPost,
 		arg.Title,
 		arg.Url,
 		arg.Description,
 		arg.PublishedAt,
 		arg.FeedID,
+		arg.Content,
+		arg.Author,
+		pq.Array(arg.Categories),
+		arg.CommentsUrl,
 	)
+	var id int32
+	err := row.Scan(&id)
+	return id, err
+}
+
+const deleteAllPosts = `-- name: DeleteAllPosts :exec
+DELETE FROM posts
+`
+
+func (q *Queries) DeleteAllPosts(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, deleteAllPosts)
 	return err
 }
 
+const deleteFeedPosts = `-- name: DeleteFeedPosts :exec
+DELETE FROM posts
+WHERE feed_id = $1
+`
+
+func (q *Queries) DeleteFeedPosts(ctx context.Context, feedID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteFeedPosts, feedID)
+	return err
+}
+
+const expirePrunedPosts = `-- name: ExpirePrunedPosts :execrows
+DELETE FROM pruned_posts
+WHERE last_seen_at < NOW() - make_interval(days => $1::int)
+`
+
+func (q *Queries) ExpirePrunedPosts(ctx context.Context, days int32) (int64, error) {
+	result, err := q.db.ExecContext(ctx, expirePrunedPosts, days)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getFeedPostStats = `-- name: GetFeedPostStats :one
+SELECT
+    COUNT(*) AS posts,
+    COUNT(*) FILTER (WHERE published_at > NOW() - INTERVAL '30 days') AS recent_posts,
+    COALESCE(EXTRACT(EPOCH FROM MAX(published_at) - MIN(published_at)), 0)::float8 AS span_seconds
+FROM posts
+WHERE feed_id = $1
+`
+
+type GetFeedPostStatsRow struct {
+	Posts       int64
+	RecentPosts int64
+	SpanSeconds float64
+}
+
+func (q *Queries) GetFeedPostStats(ctx context.Context, feedID uuid.UUID) (GetFeedPostStatsRow, error) {
+	row := q.db.QueryRowContext(ctx, getFeedPostStats, feedID)
+	var i GetFeedPostStatsRow
+	err := row.Scan(
+		&i.Posts,
+		&i.RecentPosts,
+		&i.SpanSeconds,
+	)
+	return i, err
+}
+
+const getPostForUser = `-- name: GetPostForUser :one
+SELECT p.id, p.created_at, p.updated_at, p.title, p.url, p.description, p.published_at, p.feed_id, p.content, p.author, p.categories, p.comments_url
+FROM posts p
+JOIN feed_follows ff ON p.feed_id = ff.feed_id
+WHERE p.id = $1 AND ff.user_id = $2
+`
+
+type GetPostForUserParams struct {
+	ID     int32
+	UserID uuid.UUID
+}
+
+func (q *Queries) GetPostForUser(ctx context.Context, arg GetPostForUserParams) (Post, error) {
+	row := q.db.QueryRowContext(ctx, getPostForUser, arg.ID, arg.UserID)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedID,
+		&i.Content,
+		&i.Author,
+		pq.Array(&i.Categories),
+		&i.CommentsUrl,
+	)
+	return i, err
+}
+
 const getPostsForUser = `-- name: GetPostsForUser :many
-SELECT p.id, p.created_at, p.updated_at, p.title, p.url, p.description, p.published_at, p.feed_id
+SELECT p.id, p.created_at, p.updated_at, p.title, p.url, p.description, p.published_at, p.feed_id, p.content, p.author, p.categories, p.comments_url
 FROM posts p
 JOIN feed_follows ff ON p.feed_id = ff.feed_id
 WHERE ff.user_id = $1
@@ -83,6 +194,10 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 			&i.Description,
 			&i.PublishedAt,
 			&i.FeedID,
+			&i.Content,
+			&i.Author,
+			pq.Array(&i.Categories),
+			&i.CommentsUrl,
 		); err != nil {
 			return nil, err
 		}
@@ -96,3 +211,208 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 	}
 	return items, nil
 }
+
+const getPrunablePosts = `-- name: GetPrunablePosts :many
+WITH ranked AS (
+    SELECT
+        p.id,
+        p.feed_id,
+        p.published_at,
+        ROW_NUMBER() OVER (PARTITION BY p.feed_id ORDER BY p.published_at DESC, p.id DESC) AS position,
+        COALESCE(f.retention_posts, $1::int) AS keep_posts,
+        COALESCE(f.retention_days, $2::int) AS keep_days
+    FROM posts p
+    JOIN feeds f ON f.id = p.feed_id
+), prunable AS (
+    SELECT r.id
+    FROM ranked r
+    WHERE ((r.keep_posts > 0 AND r.position > r.keep_posts)
+        OR (r.keep_days > 0 AND r.published_at < NOW() - make_interval(days => r.keep_days)))
+      AND NOT EXISTS (
+          SELECT 1 FROM post_states ps
+          WHERE ps.post_id = r.id AND ps.starred
+      )
+      AND NOT ($3::bool AND EXISTS (
+          SELECT 1 FROM feed_follows ff
+          WHERE ff.feed_id = r.feed_id
+            AND NOT EXISTS (
+                SELECT 1 FROM post_states ps
+                WHERE ps.post_id = r.id AND ps.user_id = ff.user_id AND ps.read_at IS NOT NULL
+            )
+      ))
+)
+SELECT p.id, p.feed_id, f.name AS feed_name
+FROM prunable pr
+JOIN posts p ON p.id = pr.id
+JOIN feeds f ON f.id = p.feed_id
+ORDER BY f.name, f.id, p.id
+`
+
+type GetPrunablePostsParams struct {
+	DefaultPosts  int32
+	DefaultDays   int32
+	ProtectUnread bool
+}
+
+type GetPrunablePostsRow struct {
+	ID       int32
+	FeedID   uuid.UUID
+	FeedName string
+}
+
+func (q *Queries) GetPrunablePosts(ctx context.Context, arg GetPrunablePostsParams) ([]GetPrunablePostsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPrunablePosts, arg.DefaultPosts, arg.DefaultDays, arg.ProtectUnread)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPrunablePostsRow
+	for rows.Next() {
+		var i GetPrunablePostsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.FeedID,
+			&i.FeedName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const moveFeedPrunedPosts = `-- name: MoveFeedPrunedPosts :execrows
+UPDATE pruned_posts
+SET feed_id = $1
+WHERE feed_id = $2
+`
+
+type MoveFeedPrunedPostsParams struct {
+	ToFeedID   uuid.UUID
+	FromFeedID uuid.UUID
+}
+
+func (q *Queries) MoveFeedPrunedPosts(ctx context.Context, arg MoveFeedPrunedPostsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, moveFeedPrunedPosts, arg.ToFeedID, arg.FromFeedID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const prunePosts = `-- name: PrunePosts :many
+WITH deleted AS (
+    DELETE FROM posts p
+    WHERE p.id = ANY($1::int[])
+      AND NOT EXISTS (
+          SELECT 1 FROM post_states ps
+          WHERE ps.post_id = p.id AND ps.starred
+      )
+    RETURNING p.url, p.feed_id
+), tombstones AS (
+    INSERT INTO pruned_posts (url, feed_id, pruned_at)
+    SELECT url, feed_id, NOW() FROM deleted
+    ON CONFLICT (url) DO UPDATE SET pruned_at = EXCLUDED.pruned_at, last_seen_at = EXCLUDED.pruned_at
+)
+SELECT f.name AS feed_name, COUNT(*) AS posts
+FROM deleted d
+JOIN feeds f ON f.id = d.feed_id
+GROUP BY f.id, f.name
+ORDER BY f.name
+`
+
+type PrunePostsRow struct {
+	FeedName string
+	Posts    int64
+}
+
+func (q *Queries) PrunePosts(ctx context.Context, ids []int32) ([]PrunePostsRow, error) {
+	rows, err := q.db.QueryContext(ctx, prunePosts, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PrunePostsRow
+	for rows.Next() {
+		var i PrunePostsRow
+		if err := rows.Scan(
+			&i.FeedName,
+			&i.Posts,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const seePrunedPost = `-- name: SeePrunedPost :execrows
+UPDATE pruned_posts
+SET last_seen_at = NOW()
+WHERE url = $1
+`
+
+func (q *Queries) SeePrunedPost(ctx context.Context, url string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, seePrunedPost, url)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updatePostFromFeed = `-- name: UpdatePostFromFeed :execrows
+UPDATE posts
+SET
+    updated_at = NOW(),
+    title = $1,
+    description = $2,
+    content = CASE WHEN $3::text = '' THEN content ELSE $3::text END,
+    author = $4,
+    categories = $5::text[],
+    comments_url = $6
+WHERE url = $7
+  AND (title <> $1
+    OR description <> $2
+    OR ($3::text <> '' AND content <> $3::text)
+    OR author <> $4
+    OR categories <> $5::text[]
+    OR comments_url <> $6)
+`
+
+type UpdatePostFromFeedParams struct {
+	Title       string
+	Description string
+	Content     string
+	Author      string
+	Categories  []string
+	CommentsUrl string
+	Url         string
+}
+
+func (q *Queries) UpdatePostFromFeed(ctx context.Context, arg UpdatePostFromFeedParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updatePostFromFeed,
+		arg.Title,
+		arg.Description,
+		arg.Content,
+		arg.Author,
+		pq.Array(arg.Categories),
+		arg.CommentsUrl,
+		arg.Url,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -12,22 +12,38 @@ import (
 	"github.com/google/uuid"
 )
 
+const countAdmins = `-- name: CountAdmins :one
+SELECT COUNT(*)
+FROM users
+WHERE role = 'admin'
+`
+
+func (q *Queries) CountAdmins(ctx context.Context) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countAdmins)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createUser = `-- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, name)
+INSERT INTO users (id, created_at, updated_at, name, password_hash, role)
 VALUES (
     $1,
     $2,
     $3,
-    $4
+    $4,
+    $5,
+    CASE WHEN EXISTS (SELECT 1 FROM users) THEN 'member' ELSE 'admin' END
 )
-RETURNING id, created_at, updated_at, name
+RETURNING id, created_at, updated_at, name, password_hash, role
 `
 
 type CreateUserParams struct {
-	ID        uuid.UUID
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	Name      string
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Name         string
+	PasswordHash string
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
@@ -36,6 +52,7 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		arg.CreatedAt,
 		arg.UpdatedAt,
 		arg.Name,
+		arg.PasswordHash,
 	)
 	var i User
 	err := row.Scan(
@@ -43,12 +60,24 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Name,
+		&i.PasswordHash,
+		&i.Role,
 	)
 	return i, err
 }
 
+const deleteUser = `-- name: DeleteUser :exec
+DELETE FROM users
+WHERE id = $1
+`
+
+func (q *Queries) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteUser, id)
+	return err
+}
+
 const getUser = `-- name: GetUser :one
-SELECT id, created_at, updated_at, name
+SELECT id, created_at, updated_at, name, password_hash, role
 FROM users
 WHERE name = $1
 `
@@ -61,12 +90,14 @@ func (q *Queries) GetUser(ctx context.Context, name string) (User, error) {
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Name,
+		&i.PasswordHash,
+		&i.Role,
 	)
 	return i, err
 }
 
 const getUserById = `-- name: GetUserById :one
-SELECT id, created_at, updated_at, name
+SELECT id, created_at, updated_at, name, password_hash, role
 FROM users
 WHERE id = $1
 `
@@ -79,12 +110,47 @@ func (q *Queries) GetUserById(ctx context.Context, id uuid.UUID) (User, error) {
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Name,
+		&i.PasswordHash,
+		&i.Role,
+	)
+	return i, err
+}
+
+const getUserStats = `-- name: GetUserStats :one
+SELECT
+  (SELECT COUNT(*) FROM feeds f WHERE f.user_id = $1) AS feeds_added,
+  (SELECT COUNT(*) FROM feed_follows ff WHERE ff.user_id = $1) AS feeds_followed,
+  (SELECT COUNT(*) FROM post_states ps WHERE ps.user_id = $1 AND ps.read_at IS NOT NULL) AS posts_read,
+  (SELECT COUNT(*) FROM post_states ps WHERE ps.user_id = $1 AND ps.starred) AS posts_starred,
+  (SELECT COUNT(DISTINCT ff.user_id)
+   FROM feed_follows ff
+   JOIN feeds f ON ff.feed_id = f.id
+   WHERE f.user_id = $1 AND ff.user_id <> $1) AS other_followers
+`
+
+type GetUserStatsRow struct {
+	FeedsAdded     int64
+	FeedsFollowed  int64
+	PostsRead      int64
+	PostsStarred   int64
+	OtherFollowers int64
+}
+
+func (q *Queries) GetUserStats(ctx context.Context, userID uuid.UUID) (GetUserStatsRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserStats, userID)
+	var i GetUserStatsRow
+	err := row.Scan(
+		&i.FeedsAdded,
+		&i.FeedsFollowed,
+		&i.PostsRead,
+		&i.PostsStarred,
+		&i.OtherFollowers,
 	)
 	return i, err
 }
 
 const getUsers = `-- name: GetUsers :many
-SELECT id, created_at, updated_at, name
+SELECT id, created_at, updated_at, name, password_hash, role
 FROM users
 `
 
@@ -102,6 +168,8 @@ func (q *Queries) GetUsers(ctx context.Context) ([]User, error) {
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.Name,
+			&i.PasswordHash,
+			&i.Role,
 		); err != nil {
 			return nil, err
 		}
@@ -116,6 +184,34 @@ func (q *Queries) GetUsers(ctx context.Context) ([]User, error) {
 	return items, nil
 }
 
+const lockUserCreation = `-- name: LockUserCreation :exec
+SELECT pg_advisory_xact_lock(hashtext('gator.create_user'))
+`
+
+// Held until the transaction ends, so concurrent registrations cannot both
+// see an empty users table and both become admin.
+func (q *Queries) LockUserCreation(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, lockUserCreation)
+	return err
+}
+
+const renameUser = `-- name: RenameUser :exec
+UPDATE users
+SET name = $2,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+type RenameUserParams struct {
+	ID   uuid.UUID
+	Name string
+}
+
+func (q *Queries) RenameUser(ctx context.Context, arg RenameUserParams) error {
+	_, err := q.db.ExecContext(ctx, renameUser, arg.ID, arg.Name)
+	return err
+}
+
 const reset = `-- name: Reset :exec
 DELETE FROM users
 `
@@ -124,3 +220,57 @@ func (q *Queries) Reset(ctx context.Context) error {
 	_, err := q.db.ExecContext(ctx, reset)
 	return err
 }
+
+const setInitialUserPassword = `-- name: SetInitialUserPassword :execrows
+UPDATE users
+SET password_hash = $2,
+    updated_at = NOW()
+WHERE id = $1 AND password_hash = ''
+`
+
+type SetInitialUserPasswordParams struct {
+	ID           uuid.UUID
+	PasswordHash string
+}
+
+func (q *Queries) SetInitialUserPassword(ctx context.Context, arg SetInitialUserPasswordParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setInitialUserPassword, arg.ID, arg.PasswordHash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setUserPassword = `-- name: SetUserPassword :exec
+UPDATE users
+SET password_hash = $2,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+type SetUserPasswordParams struct {
+	ID           uuid.UUID
+	PasswordHash string
+}
+
+func (q *Queries) SetUserPassword(ctx context.Context, arg SetUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, setUserPassword, arg.ID, arg.PasswordHash)
+	return err
+}
+
+const setUserRole = `-- name: SetUserRole :exec
+UPDATE users
+SET role = $2,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+type SetUserRoleParams struct {
+	ID   uuid.UUID
+	Role string
+}
+
+func (q *Queries) SetUserRole(ctx context.Context, arg SetUserRoleParams) error {
+	_, err := q.db.ExecContext(ctx, setUserRole, arg.ID, arg.Role)
+	return err
+}
@@ -0,0 +1,96 @@
+package fetch
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"regexp"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+)
+
+// prologEncoding matches the encoding declared in an XML prolog such as
+// <?xml version="1.0" encoding="ISO-8859-1"?>.
+var prologEncoding = regexp.MustCompile(`^\s*<\?xml[^>]*?\sencoding\s*=\s*["']([A-Za-z0-9._:-]+)["']`)
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+)
+
+// XMLBody returns the response body transcoded to UTF-8, without any byte
+// order mark. The prolog is left as is, so decoders must ignore the encoding
+// it declares. The encoding comes from a byte order mark, then the charset in
+// the Content-Type header, then the XML prolog. Servers often label
+// everything UTF-8, so a body that claims to be UTF-8 but isn't is read with
+// the prolog's encoding instead, or as Windows-1252 when the prolog doesn't
+// name one.
+func (r *Response) XMLBody() ([]byte, error) {
+	body := r.Body
+	prolog := ""
+	if m := prologEncoding.FindSubmatch(body); m != nil {
+		prolog = string(m[1])
+	}
+	var label string
+	switch {
+	case bytes.HasPrefix(body, bomUTF8):
+		body, label = body[len(bomUTF8):], "utf-8"
+	case bytes.HasPrefix(body, bomUTF16BE):
+		label = "utf-16be"
+	case bytes.HasPrefix(body, bomUTF16LE):
+		label = "utf-16le"
+	default:
+		label = contentTypeCharset(r.ContentType)
+		if label == "" {
+			label = prolog
+		}
+	}
+	name := canonicalCharset(label)
+	if name == "utf-8" && !utf8.Valid(body) {
+		name = "windows-1252"
+		if fallback := canonicalCharset(prolog); fallback != "utf-8" {
+			name = fallback
+		}
+	}
+	if name == "utf-8" {
+		return body, nil
+	}
+	reader, err := charset.NewReaderLabel(name, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimPrefix(data, bomUTF8), nil
+}
+
+// HTMLReader returns the response body transcoded to UTF-8, using the
+// Content-Type header, a byte order mark or a <meta> charset in the page.
+func (r *Response) HTMLReader() (io.Reader, error) {
+	return charset.NewReader(bytes.NewReader(r.Body), r.ContentType)
+}
+
+// canonicalCharset returns the WHATWG name for a charset label. Empty and
+// unknown labels are treated as UTF-8.
+func canonicalCharset(label string) string {
+	enc, name := charset.Lookup(label)
+	if enc == nil {
+		return "utf-8"
+	}
+	return name
+}
+
+func contentTypeCharset(contentType string) string {
+	if contentType == "" {
+		return ""
+	}
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return params["charset"]
+}
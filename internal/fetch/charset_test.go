@@ -0,0 +1,98 @@
+package fetch
+
+import (
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+)
+
+func TestXMLBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+		want        string
+	}{
+		{
+			name: "UTF-8",
+			body: []byte(`<?xml version="1.0"?><title>café</title>`),
+			want: `<?xml version="1.0"?><title>café</title>`,
+		},
+		{
+			name: "UTF-8 byte order mark",
+			body: []byte("\xEF\xBB\xBF<title>café</title>"),
+			want: "<title>café</title>",
+		},
+		{
+			name:        "ISO-8859-1 from Content-Type",
+			contentType: "application/rss+xml; charset=ISO-8859-1",
+			body:        []byte("<title>caf\xE9</title>"),
+			want:        "<title>café</title>",
+		},
+		{
+			name: "ISO-8859-1 from prolog",
+			body: []byte(`<?xml version="1.0" encoding="ISO-8859-1"?><title>caf` + "\xE9" + `</title>`),
+			want: `<?xml version="1.0" encoding="ISO-8859-1"?><title>café</title>`,
+		},
+		{
+			name:        "Content-Type wins over prolog",
+			contentType: "text/xml; charset=Shift_JIS",
+			body:        []byte(`<?xml version="1.0" encoding="ISO-8859-1"?><title>` + "\x93\xFA\x96\x7B" + `</title>`),
+			want:        `<?xml version="1.0" encoding="ISO-8859-1"?><title>日本</title>`,
+		},
+		{
+			name: "Shift_JIS from prolog",
+			body: []byte(`<?xml version="1.0" encoding="Shift_JIS"?><title>` + "\x93\xFA\x96\x7B" + `</title>`),
+			want: `<?xml version="1.0" encoding="Shift_JIS"?><title>日本</title>`,
+		},
+		{
+			name:        "UTF-16LE byte order mark wins over Content-Type",
+			contentType: "text/xml; charset=utf-8",
+			body:        utf16Bytes(binary.LittleEndian, []byte{0xFF, 0xFE}, "<title>日本</title>"),
+			want:        "<title>日本</title>",
+		},
+		{
+			name: "UTF-16BE byte order mark",
+			body: utf16Bytes(binary.BigEndian, []byte{0xFE, 0xFF}, "<title>café</title>"),
+			want: "<title>café</title>",
+		},
+		{
+			name:        "mislabelled UTF-8 falls back to prolog",
+			contentType: "application/xml; charset=utf-8",
+			body:        []byte(`<?xml version="1.0" encoding="iso-8859-1"?><title>caf` + "\xE9" + `</title>`),
+			want:        `<?xml version="1.0" encoding="iso-8859-1"?><title>café</title>`,
+		},
+		{
+			name:        "mislabelled UTF-8 without prolog encoding is Windows-1252",
+			contentType: "application/xml; charset=utf-8",
+			body:        []byte("<title>\x80 caf\xE9</title>"),
+			want:        "<title>€ café</title>",
+		},
+		{
+			name:        "unknown charset is UTF-8",
+			contentType: "text/xml; charset=x-unknown",
+			body:        []byte("<title>café</title>"),
+			want:        "<title>café</title>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Response{Body: tt.body, ContentType: tt.contentType}
+			got, err := r.XMLBody()
+			if err != nil {
+				t.Fatalf("XMLBody: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("XMLBody = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func utf16Bytes(order binary.AppendByteOrder, bom []byte, s string) []byte {
+	out := append([]byte{}, bom...)
+	for _, u := range utf16.Encode([]rune(s)) {
+		out = order.AppendUint16(out, u)
+	}
+	return out
+}
@@ -0,0 +1,258 @@
+package fetch
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+const (
+	DefaultTimeout     = 30 * time.Second
+	DefaultMaxBodySize = 10 << 20
+	// MaxRedirects is how many redirects a request follows before giving up.
+	MaxRedirects = 10
+)
+
+// projectURL is included in the User-Agent so site owners can find out what
+// is fetching their feeds.
+const projectURL = "https://github.com/UUest/gator"
+
+var (
+	// ErrGone is returned when the server answers 410 Gone, meaning the
+	// resource was removed for good.
+	ErrGone = errors.New("resource is gone")
+	// ErrTooLarge is returned when a response body is bigger than the
+	// fetcher's maximum body size.
+	ErrTooLarge = errors.New("response body too large")
+)
+
+// StatusError reports a response with a status other than 200 OK.
+type StatusError struct {
+	StatusCode int
+	Status     string
+	// RetryAfter is how long the server asked us to wait before trying
+	// again, from the Retry-After header of a 429 or 503 response.
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("unexpected status %s, retry after %s", e.Status, e.RetryAfter)
+	}
+	return fmt.Sprintf("unexpected status %s", e.Status)
+}
+
+// Options configures a Fetcher. Zero values select the defaults.
+type Options struct {
+	// Timeout bounds a whole Get request, including reading the body. For
+	// Do it only bounds connecting and waiting for the response headers.
+	Timeout     time.Duration
+	MaxBodySize int64
+	// Contact, usually an email address or URL, is added to the User-Agent
+	// so site owners can reach whoever runs this instance.
+	Contact string
+	// Proxy is the URL of an HTTP or SOCKS5 proxy. When empty the standard
+	// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables apply.
+	Proxy string
+}
+
+// Fetcher makes outgoing HTTP requests with shared timeouts, size limits,
+// User-Agent and proxy settings.
+type Fetcher struct {
+	client      *http.Client
+	noRedirect  *http.Client
+	timeout     time.Duration
+	maxBodySize int64
+	userAgent   string
+}
+
+// Response is a fully read, decoded response body.
+type Response struct {
+	Body []byte
+	// URL is where the body was fetched from after following redirects.
+	URL string
+	// Permanent reports whether every redirect followed was permanent (301
+	// or 308). It is false when there were no redirects.
+	Permanent   bool
+	ContentType string
+}
+
+// Moved returns the URL a request for requestURL was permanently redirected
+// to, or "" if it was not.
+func (r *Response) Moved(requestURL string) string {
+	if r.Permanent && r.URL != requestURL {
+		return r.URL
+	}
+	return ""
+}
+
+func New(opts Options) (*Fetcher, error) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.MaxBodySize <= 0 {
+		opts.MaxBodySize = DefaultMaxBodySize
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: opts.Timeout}).DialContext
+	transport.TLSHandshakeTimeout = opts.Timeout
+	transport.ResponseHeaderTimeout = opts.Timeout
+	if opts.Proxy != "" {
+		proxyURL, err := url.Parse(opts.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	userAgent := "gator (+" + projectURL
+	if opts.Contact != "" {
+		userAgent += "; " + opts.Contact
+	}
+	userAgent += ")"
+	return &Fetcher{
+		client: &http.Client{
+			Transport: transport,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= MaxRedirects {
+					return fmt.Errorf("stopped after %d redirects", MaxRedirects)
+				}
+				return nil
+			},
+		},
+		noRedirect: &http.Client{
+			Transport: transport,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		timeout:     opts.Timeout,
+		maxBodySize: opts.MaxBodySize,
+		userAgent:   userAgent,
+	}, nil
+}
+
+// Get fetches rawURL and returns its decoded body. Responses other than 200
+// OK are returned as ErrGone or a *StatusError.
+func (f *Fetcher) Get(ctx context.Context, rawURL string) (*Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Setting Accept-Encoding ourselves turns off the transport's transparent
+	// gzip support, so decoding happens in decodeBody.
+	req.Header.Set("Accept-Encoding", "gzip, br")
+	resp, err := f.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+	body, err := decodeBody(resp)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(io.LimitReader(body, f.maxBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > f.maxBodySize {
+		return nil, ErrTooLarge
+	}
+	return &Response{
+		Body:        data,
+		URL:         resp.Request.URL.String(),
+		Permanent:   permanentRedirect(resp),
+		ContentType: resp.Header.Get("Content-Type"),
+	}, nil
+}
+
+// Do sends req with the fetcher's User-Agent and returns the response as is,
+// for callers that stream large bodies or handle statuses themselves.
+func (f *Fetcher) Do(req *http.Request) (*http.Response, error) {
+	req.Header.Set("User-Agent", f.userAgent)
+	return f.client.Do(req)
+}
+
+// DoNoRedirect is like Do but returns a redirect response as is instead of
+// following it, for requests such as webhook deliveries that must not be
+// resent to wherever the server points.
+func (f *Fetcher) DoNoRedirect(req *http.Request) (*http.Response, error) {
+	req.Header.Set("User-Agent", f.userAgent)
+	return f.noRedirect.Do(req)
+}
+
+func checkStatus(resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusGone:
+		return ErrGone
+	}
+	return NewStatusError(resp)
+}
+
+// NewStatusError describes an unexpected response, for callers of Do that
+// check statuses themselves.
+func NewStatusError(resp *http.Response) *StatusError {
+	statusErr := &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		statusErr.RetryAfter = retryAfter(resp.Header.Get("Retry-After"))
+	}
+	return statusErr
+}
+
+// retryAfter parses a Retry-After header, which holds either a number of
+// seconds or an HTTP date.
+func retryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if when, err := http.ParseTime(value); err == nil {
+		return max(time.Until(when).Round(time.Second), 0)
+	}
+	return 0
+}
+
+func decodeBody(resp *http.Response) (io.Reader, error) {
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "", "identity":
+		return resp.Body, nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(resp.Body)
+	case "br":
+		return brotli.NewReader(resp.Body), nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", resp.Header.Get("Content-Encoding"))
+	}
+}
+
+// permanentRedirect reports whether resp was reached through redirects that
+// were all permanent. Each redirected request keeps the response that caused
+// it, so the chain can be walked back to the original request.
+func permanentRedirect(resp *http.Response) bool {
+	redirected := false
+	for prev := resp.Request.Response; prev != nil; prev = prev.Request.Response {
+		if prev.StatusCode != http.StatusMovedPermanently && prev.StatusCode != http.StatusPermanentRedirect {
+			return false
+		}
+		redirected = true
+	}
+	return redirected
+}
@@ -0,0 +1,183 @@
+package fetch
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	redirect := func(path, to string, code int) {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, to, code)
+		})
+	}
+	mux.HandleFunc("/feed", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte("<rss/>"))
+	})
+	redirect("/moved", "/moved-again", http.StatusMovedPermanently)
+	redirect("/moved-again", "/feed", http.StatusPermanentRedirect)
+	redirect("/mixed", "/temporary", http.StatusMovedPermanently)
+	redirect("/temporary", "/feed", http.StatusFound)
+	redirect("/found", "/moved-again", http.StatusFound)
+	redirect("/loop", "/loop", http.StatusMovedPermanently)
+	mux.HandleFunc("/gone", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	})
+	mux.HandleFunc("/busy", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "120")
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("/gzip", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		zw.Write([]byte("<rss/>"))
+		zw.Close()
+	})
+	mux.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 2048)))
+	})
+	mux.HandleFunc("/agent", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.UserAgent()))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestGetRedirects(t *testing.T) {
+	srv := newTestServer(t)
+	f, err := New(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		path  string
+		moved string
+	}{
+		{"no redirect", "/feed", ""},
+		{"301 then 308", "/moved", "/feed"},
+		{"301 then 302", "/mixed", ""},
+		{"302 then 308", "/found", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := f.Get(context.Background(), srv.URL+tt.path)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			if resp.URL != srv.URL+"/feed" {
+				t.Errorf("URL = %s, want %s", resp.URL, srv.URL+"/feed")
+			}
+			want := ""
+			if tt.moved != "" {
+				want = srv.URL + tt.moved
+			}
+			if got := resp.Moved(srv.URL + tt.path); got != want {
+				t.Errorf("Moved = %q, want %q", got, want)
+			}
+		})
+	}
+
+	if _, err := f.Get(context.Background(), srv.URL+"/loop"); err == nil {
+		t.Errorf("Get of a redirect loop succeeded")
+	}
+}
+
+func TestGetStatuses(t *testing.T) {
+	srv := newTestServer(t)
+	f, err := New(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if _, err := f.Get(ctx, srv.URL+"/gone"); !errors.Is(err, ErrGone) {
+		t.Errorf("Get of 410 = %v, want ErrGone", err)
+	}
+
+	var statusErr *StatusError
+	_, err = f.Get(ctx, srv.URL+"/busy")
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("Get of 429 = %v, want a *StatusError", err)
+	}
+	if statusErr.StatusCode != http.StatusTooManyRequests || statusErr.RetryAfter != 2*time.Minute {
+		t.Errorf("Get of 429 = %d retry after %s, want 429 retry after 2m", statusErr.StatusCode, statusErr.RetryAfter)
+	}
+
+	// Retry-After only means something on 429 and 503.
+	_, err = f.Get(ctx, srv.URL+"/missing")
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("Get of 404 = %v, want a *StatusError", err)
+	}
+	if statusErr.StatusCode != http.StatusNotFound || statusErr.RetryAfter != 0 {
+		t.Errorf("Get of 404 = %d retry after %s, want 404 without retry", statusErr.StatusCode, statusErr.RetryAfter)
+	}
+}
+
+func TestGetBody(t *testing.T) {
+	srv := newTestServer(t)
+	f, err := New(Options{MaxBodySize: 1024, Contact: "ops@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	resp, err := f.Get(ctx, srv.URL+"/gzip")
+	if err != nil {
+		t.Fatalf("Get of gzip body: %v", err)
+	}
+	if string(resp.Body) != "<rss/>" {
+		t.Errorf("gzip body = %q, want <rss/>", resp.Body)
+	}
+
+	if _, err := f.Get(ctx, srv.URL+"/large"); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Get of large body = %v, want ErrTooLarge", err)
+	}
+
+	resp, err = f.Get(ctx, srv.URL+"/agent")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if agent := string(resp.Body); !strings.HasPrefix(agent, "gator (+") || !strings.Contains(agent, "ops@example.com") {
+		t.Errorf("User-Agent = %q, want gator with the contact address", agent)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"30", 30 * time.Second},
+		{"0", 0},
+		{"-5", 0},
+		{"soon", 0},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0},
+	}
+	for _, tt := range tests {
+		if got := retryAfter(tt.value); got != tt.want {
+			t.Errorf("retryAfter(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+
+	// Dates are rounded to the second, so allow for the clock ticking over.
+	value := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if got := retryAfter(value); got < 59*time.Minute || got > time.Hour {
+		t.Errorf("retryAfter(%q) = %s, want about an hour", value, got)
+	}
+}
@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func encode(m metric) string {
+	var b strings.Builder
+	w := bufio.NewWriter(&b)
+	m.write(w)
+	w.Flush()
+	return b.String()
+}
+
+func TestCounter(t *testing.T) {
+	c := NewCounter("test_requests_total", "Requests by \"result\".\nSecond line with a \\.", "result", "host")
+	c.Inc("ok", "b.example")
+	c.Inc("ok", "a.example")
+	c.Add(2.5, "ok", "a.example")
+	c.Inc("failed", `x"y\z`+"\n")
+	want := `# HELP test_requests_total Requests by "result".\nSecond line with a \\.
+# TYPE test_requests_total counter
+test_requests_total{result="failed",host="x\"y\\z\n"} 1
+test_requests_total{result="ok",host="a.example"} 3.5
+test_requests_total{result="ok",host="b.example"} 1
+`
+	if got := encode(c); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCounterWithoutLabels(t *testing.T) {
+	c := NewCounter("test_events_total", "Events.")
+	if got, want := encode(c), "# HELP test_events_total Events.\n# TYPE test_events_total counter\n"; got != want {
+		t.Errorf("before any event got %q, want %q", got, want)
+	}
+	c.Inc()
+	c.Inc()
+	if got := encode(c); !strings.HasSuffix(got, "\ntest_events_total 2\n") {
+		t.Errorf("got %q, want a single unlabelled series", got)
+	}
+}
+
+func TestCounterWrongLabels(t *testing.T) {
+	c := NewCounter("test_labelled_total", "Labelled.", "a")
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Inc with the wrong number of label values didn't panic")
+		}
+	}()
+	c.Inc("x", "y")
+}
+
+func TestGauge(t *testing.T) {
+	g := NewGauge("test_backlog", "Backlog.")
+	g.Set(3)
+	g.Set(0.25)
+	want := "# HELP test_backlog Backlog.\n# TYPE test_backlog gauge\ntest_backlog 0.25\n"
+	if got := encode(g); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram("test_duration_seconds", "Durations.", []float64{1, 0.5}, "feed")
+	for _, v := range []float64{0.2, 0.5, 0.7, 3} {
+		h.Observe(v, "a")
+	}
+	want := `# HELP test_duration_seconds Durations.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{feed="a",le="0.5"} 2
+test_duration_seconds_bucket{feed="a",le="1"} 3
+test_duration_seconds_bucket{feed="a",le="+Inf"} 4
+test_duration_seconds_sum{feed="a"} 4.4
+test_duration_seconds_count{feed="a"} 4
+`
+	if got := encode(h); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	NewGauge("test_handler_gauge", "Served.").Set(1)
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q, want the Prometheus text format", ct)
+	}
+	body, _ := io.ReadAll(rec.Body)
+	if !strings.Contains(string(body), "\ntest_handler_gauge 1\n") {
+		t.Errorf("body doesn't include the registered gauge:\n%s", body)
+	}
+}
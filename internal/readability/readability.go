@@ -0,0 +1,184 @@
+package readability
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var ErrNoContent = errors.New("no article content found")
+
+var (
+	unlikelyRe = regexp.MustCompile(`(?i)banner|breadcrumb|combx|comment|community|cookie|disqus|extra|footer|header|menu|modal|nav|popup|related|remark|replies|rss|share|shoutbox|sidebar|skyscraper|social|sponsor|subscribe|widget`)
+	maybeRe    = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow`)
+	positiveRe = regexp.MustCompile(`(?i)article|blog|body|content|entry|hentry|h-entry|main|page|post|story|text`)
+	negativeRe = regexp.MustCompile(`(?i)-ad-|ad-break|agegate|comment|com-|contact|footer|footnote|masthead|media|meta|outbrain|promo|related|scroll|share|shopping|sidebar|sponsor|tags|widget`)
+)
+
+var removeTags = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Iframe: true,
+	atom.Form: true, atom.Nav: true, atom.Aside: true, atom.Footer: true,
+	atom.Header: true, atom.Button: true, atom.Input: true, atom.Select: true,
+	atom.Svg: true, atom.Link: true, atom.Meta: true,
+}
+
+var scoredTags = map[atom.Atom]bool{
+	atom.P: true, atom.Pre: true, atom.Td: true, atom.Blockquote: true,
+}
+
+// minParagraphLength is the shortest paragraph that contributes to the score
+// of its ancestors; shorter runs of text are usually captions or bylines.
+const minParagraphLength = 25
+
+// Extract finds the main article in an HTML page and returns it as an HTML
+// fragment. It follows the approach of Arc90's readability: paragraphs award
+// points to their parent and grandparent, containers are weighted by their
+// class and id names and penalised by link density, and the best scoring
+// container wins.
+func Extract(r io.Reader) (string, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return "", err
+	}
+	clean(doc)
+
+	scores := map[*html.Node]float64{}
+	var candidates []*html.Node
+	walk(doc, func(n *html.Node) {
+		if n.Type != html.ElementNode || !scoredTags[n.DataAtom] {
+			return
+		}
+		text := textContent(n)
+		if len(text) < minParagraphLength {
+			return
+		}
+		score := 1 + float64(strings.Count(text, ",")) + min(float64(len(text))/100, 3)
+		parent := n.Parent
+		if parent == nil {
+			return
+		}
+		for i, ancestor := range []*html.Node{parent, parent.Parent} {
+			if ancestor == nil || ancestor.Type != html.ElementNode {
+				continue
+			}
+			if _, ok := scores[ancestor]; !ok {
+				scores[ancestor] = initialScore(ancestor)
+				candidates = append(candidates, ancestor)
+			}
+			scores[ancestor] += score / float64(i+1)
+		}
+	})
+
+	var best *html.Node
+	bestScore := 0.0
+	for _, c := range candidates {
+		score := scores[c] * (1 - linkDensity(c))
+		if best == nil || score > bestScore {
+			best, bestScore = c, score
+		}
+	}
+	if best == nil {
+		return "", ErrNoContent
+	}
+
+	var buf bytes.Buffer
+	for c := best.FirstChild; c != nil; c = c.NextSibling {
+		if err := html.Render(&buf, c); err != nil {
+			return "", err
+		}
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// clean removes elements that never hold article text, along with containers
+// whose class or id marks them as page furniture.
+func clean(n *html.Node) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.CommentNode || (c.Type == html.ElementNode && (removeTags[c.DataAtom] || isUnlikely(c))) {
+			n.RemoveChild(c)
+		} else {
+			clean(c)
+		}
+		c = next
+	}
+}
+
+func isUnlikely(n *html.Node) bool {
+	if n.DataAtom == atom.Body || n.DataAtom == atom.Html || n.DataAtom == atom.Article {
+		return false
+	}
+	names := classAndID(n)
+	return unlikelyRe.MatchString(names) && !maybeRe.MatchString(names)
+}
+
+func initialScore(n *html.Node) float64 {
+	score := 0.0
+	switch n.DataAtom {
+	case atom.Article:
+		score += 10
+	case atom.Div:
+		score += 5
+	case atom.Pre, atom.Td, atom.Blockquote:
+		score += 3
+	case atom.Ol, atom.Ul, atom.Dl, atom.Form:
+		score -= 3
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Th:
+		score -= 5
+	}
+	names := classAndID(n)
+	if negativeRe.MatchString(names) {
+		score -= 25
+	}
+	if positiveRe.MatchString(names) {
+		score += 25
+	}
+	return score
+}
+
+func linkDensity(n *html.Node) float64 {
+	total := len(textContent(n))
+	if total == 0 {
+		return 0
+	}
+	linked := 0
+	walk(n, func(c *html.Node) {
+		if c.Type == html.ElementNode && c.DataAtom == atom.A {
+			linked += len(textContent(c))
+		}
+	})
+	return min(float64(linked)/float64(total), 1)
+}
+
+func classAndID(n *html.Node) string {
+	var parts []string
+	for _, a := range n.Attr {
+		if a.Key == "class" || a.Key == "id" {
+			parts = append(parts, a.Val)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func textContent(n *html.Node) string {
+	var b strings.Builder
+	walk(n, func(c *html.Node) {
+		if c.Type == html.TextNode {
+			b.WriteString(c.Data)
+		}
+	})
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// walk calls fn for n and every node below it, parents before children.
+func walk(n *html.Node, fn func(*html.Node)) {
+	fn(n)
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walk(c, fn)
+	}
+}
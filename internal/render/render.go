@@ -0,0 +1,201 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+var blockTags = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "ul": true, "ol": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"blockquote": true, "pre": true, "tr": true, "table": true, "hr": true,
+	"section": true, "article": true, "figure": true, "figcaption": true,
+}
+
+var skipTags = map[string]bool{
+	"script": true, "style": true, "head": true, "title": true,
+}
+
+// Document is an HTML fragment converted to plain text, together with the
+// links it referenced in order of appearance.
+type Document struct {
+	Text  string
+	Links []string
+}
+
+// Text converts an HTML fragment such as an RSS item description into plain
+// text. Tags are dropped, entities are decoded and block elements become
+// line breaks.
+func Text(s string) string {
+	return convert(s, false).Text
+}
+
+// Parse converts an HTML fragment like Text, but also numbers every link and
+// marks its anchor text with a [n] reference into Links.
+func Parse(s string) Document {
+	return convert(s, true)
+}
+
+// Terminal renders an HTML fragment as text wrapped to width, followed by a
+// footnote list of the links it contained.
+func Terminal(s string, width int) string {
+	doc := Parse(s)
+	lines := Wrap(doc.Text, width)
+	if len(doc.Links) > 0 {
+		lines = append(lines, "")
+		for i, link := range doc.Links {
+			lines = append(lines, fmt.Sprintf("[%d] %s", i+1, link))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func convert(s string, withLinks bool) Document {
+	var b strings.Builder
+	var links []string
+	var openLinks []string
+	z := html.NewTokenizer(strings.NewReader(s))
+	skip := 0
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			return Document{Text: cleanLines(StripControl(b.String())), Links: links}
+		case html.TextToken:
+			if skip > 0 {
+				continue
+			}
+			b.WriteString(collapseSpace(string(z.Text())))
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			tag := string(name)
+			attrs := map[string]string{}
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = z.TagAttr()
+				attrs[string(key)] = string(val)
+			}
+			if skipTags[tag] && tt == html.StartTagToken {
+				skip++
+			}
+			switch {
+			case tag == "li":
+				b.WriteString("\n• ")
+			case tag == "img" && attrs["alt"] != "":
+				b.WriteString("[image: " + attrs["alt"] + "]")
+			case tag == "a" && tt == html.StartTagToken:
+				openLinks = append(openLinks, attrs["href"])
+			case blockTags[tag]:
+				b.WriteString("\n")
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			tag := string(name)
+			if skipTags[tag] && skip > 0 {
+				skip--
+			}
+			if tag == "a" && len(openLinks) > 0 {
+				href := openLinks[len(openLinks)-1]
+				openLinks = openLinks[:len(openLinks)-1]
+				if withLinks && isExternalLink(href) {
+					links = append(links, StripControl(href))
+					fmt.Fprintf(&b, "[%d]", len(links))
+				}
+			}
+			if blockTags[tag] && tag != "li" {
+				b.WriteString("\n")
+			}
+		}
+	}
+}
+
+// StripControl removes control characters other than newline and tab from s,
+// so that text from feeds and web pages can't send escape sequences to the
+// terminal it is printed on.
+func StripControl(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) && r != '\n' && r != '\t' {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+func isExternalLink(href string) bool {
+	return strings.HasPrefix(href, "http://") || strings.HasPrefix(href, "https://")
+}
+
+func collapseSpace(s string) string {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		if s != "" {
+			return " "
+		}
+		return ""
+	}
+	out := strings.Join(fields, " ")
+	if strings.TrimLeftFunc(s, unicode.IsSpace) != s {
+		out = " " + out
+	}
+	if strings.TrimRightFunc(s, unicode.IsSpace) != s {
+		out += " "
+	}
+	return out
+}
+
+// cleanLines trims every line and collapses runs of blank lines into one.
+func cleanLines(s string) string {
+	lines := strings.Split(s, "\n")
+	out := make([]string, 0, len(lines))
+	blank := true
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			if !blank {
+				out = append(out, "")
+			}
+			blank = true
+			continue
+		}
+		out = append(out, line)
+		blank = false
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
+
+// Wrap breaks s into lines no wider than width runes, keeping existing line
+// breaks. Words longer than width are split.
+func Wrap(s string, width int) []string {
+	if width < 1 {
+		width = 1
+	}
+	var lines []string
+	for _, para := range strings.Split(s, "\n") {
+		line := ""
+		for _, word := range strings.Fields(para) {
+			for len([]rune(word)) > width {
+				if line != "" {
+					lines = append(lines, line)
+					line = ""
+				}
+				r := []rune(word)
+				lines = append(lines, string(r[:width]))
+				word = string(r[width:])
+			}
+			switch {
+			case line == "":
+				line = word
+			case len([]rune(line))+1+len([]rune(word)) <= width:
+				line += " " + word
+			default:
+				lines = append(lines, line)
+				line = word
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
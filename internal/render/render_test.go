@@ -0,0 +1,32 @@
+package render
+
+import "testing"
+
+func TestTerminalStripsControlCharacters(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"entity escapes", "<p>&#27;]2;pwned&#7; ok</p>", "]2;pwned ok"},
+		{"raw escapes", "<p>\x1b[31mred\x1b[0m</p>", "[31mred[0m"},
+		{"C1 control", "<p>a\u009b31mb</p>", "a31mb"},
+		{"link footnote", `<a href="https://example.com/` + "\x1b" + `x">l</a>`, "l[1]\n\n[1] https://example.com/x"},
+		{"keeps line breaks", "<p>one</p><p>two</p>", "one\n\ntwo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Terminal(tt.html, 80); got != tt.want {
+				t.Errorf("Terminal(%q) = %q, want %q", tt.html, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStripControl(t *testing.T) {
+	got := StripControl("title\x1b]0;x\x07\r\n\tnext\u0085")
+	want := "title]0;x\n\tnext"
+	if got != want {
+		t.Errorf("StripControl = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,127 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/UUest/gator/internal/fetch"
+)
+
+// Events a payload can describe.
+const (
+	EventPostCreated = "post.created"
+	// EventTest is sent by gator webhook test and carries a sample post.
+	EventTest = "test"
+)
+
+// Headers sent with every payload. SignatureHeader holds "sha256=" and the
+// hex HMAC-SHA256 of the request body keyed with the webhook's secret.
+const (
+	EventHeader     = "X-Gator-Event"
+	DeliveryHeader  = "X-Gator-Delivery"
+	SignatureHeader = "X-Gator-Signature-256"
+)
+
+// Timeout bounds a whole delivery, including the receiver's response.
+const Timeout = 30 * time.Second
+
+// maxResponseSize is how much of a receiver's response is read before the
+// connection is closed. The body itself is ignored.
+const maxResponseSize = 64 << 10
+
+// Payload is the JSON body POSTed to a webhook.
+type Payload struct {
+	Event string `json:"event"`
+	// DeliveryID identifies the delivery across retries, so receivers can
+	// drop duplicates. It is 0 for test events.
+	DeliveryID int64     `json:"delivery_id,omitempty"`
+	WebhookID  int32     `json:"webhook_id,omitempty"`
+	SentAt     time.Time `json:"sent_at"`
+	Feed       Feed      `json:"feed"`
+	Post       Post      `json:"post"`
+}
+
+type Feed struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+	URL  string    `json:"url"`
+}
+
+type Post struct {
+	ID          int32     `json:"id"`
+	Title       string    `json:"title"`
+	URL         string    `json:"url"`
+	Description string    `json:"description,omitempty"`
+	Author      string    `json:"author,omitempty"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+// Sign returns the SignatureHeader value for body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Send POSTs payload to url, signed with secret. It returns the response
+// status, or 0 when none was received, and a *fetch.StatusError for statuses
+// outside 2xx. Redirects are not followed and count as failures.
+func Send(ctx context.Context, f *fetch.Fetcher, url, secret string, payload Payload) (int, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, payload.Event)
+	if payload.DeliveryID != 0 {
+		req.Header.Set(DeliveryHeader, strconv.FormatInt(payload.DeliveryID, 10))
+	}
+	req.Header.Set(SignatureHeader, Sign(secret, body))
+	resp, err := f.DoNoRedirect(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseSize))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fetch.NewStatusError(resp)
+	}
+	return resp.StatusCode, nil
+}
+
+// TestPayload returns a test event carrying a made-up post, so receivers can
+// be checked before any real post arrives.
+func TestPayload(webhookID int32) Payload {
+	now := time.Now().UTC()
+	return Payload{
+		Event:     EventTest,
+		WebhookID: webhookID,
+		SentAt:    now,
+		Feed: Feed{
+			Name: "gator test feed",
+			URL:  "https://example.com/feed.xml",
+		},
+		Post: Post{
+			Title:       "Test post from gator",
+			URL:         "https://example.com/gator-webhook-test",
+			Description: "This is a test delivery; no new post was stored.",
+			PublishedAt: now,
+		},
+	}
+}
@@ -0,0 +1,175 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/UUest/gator/internal/fetch"
+)
+
+func TestSign(t *testing.T) {
+	// From RFC 4231, test case 2.
+	got := Sign("Jefe", []byte("what do ya want for nothing?"))
+	want := "sha256=5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"
+	if got != want {
+		t.Errorf("Sign = %s, want %s", got, want)
+	}
+}
+
+func TestSend(t *testing.T) {
+	const secret = "s3cret"
+	var received *http.Request
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+	f, err := fetch.New(fetch.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload := Payload{
+		Event:      EventPostCreated,
+		DeliveryID: 42,
+		WebhookID:  7,
+		SentAt:     time.Date(2024, time.March, 5, 9, 30, 0, 0, time.UTC),
+		Post:       Post{ID: 3, Title: "Hello", URL: "https://example.com/hello"},
+	}
+	status, err := Send(context.Background(), f, srv.URL, secret, payload)
+	if err != nil || status != http.StatusAccepted {
+		t.Fatalf("Send = %d, %v, want 202", status, err)
+	}
+	if received.Method != http.MethodPost {
+		t.Errorf("method = %s, want POST", received.Method)
+	}
+	for header, want := range map[string]string{
+		"Content-Type": "application/json",
+		EventHeader:    EventPostCreated,
+		DeliveryHeader: "42",
+	} {
+		if got := received.Header.Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+	// Receivers check the signature the way the README describes.
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	if got, want := received.Header.Get(SignatureHeader), "sha256="+hex.EncodeToString(mac.Sum(nil)); got != want {
+		t.Errorf("%s = %q, want %q", SignatureHeader, got, want)
+	}
+	var decoded Payload
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		t.Fatalf("body is not JSON: %v", err)
+	}
+	if decoded.DeliveryID != 42 || decoded.WebhookID != 7 || decoded.Post.Title != "Hello" || !decoded.SentAt.Equal(payload.SentAt) {
+		t.Errorf("body = %+v, want %+v", decoded, payload)
+	}
+}
+
+func TestSendTestEventHasNoDeliveryID(t *testing.T) {
+	var received *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+	}))
+	defer srv.Close()
+	f, err := fetch.New(fetch.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Send(context.Background(), f, srv.URL, "x", TestPayload(1)); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if got := received.Header.Get(EventHeader); got != EventTest {
+		t.Errorf("%s = %q, want %q", EventHeader, got, EventTest)
+	}
+	if got := received.Header.Get(DeliveryHeader); got != "" {
+		t.Errorf("%s = %q, want none", DeliveryHeader, got)
+	}
+}
+
+func TestSendFailures(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		retryAfter string
+		wantRetry  time.Duration
+	}{
+		{"gone", http.StatusGone, "", 0},
+		{"server error", http.StatusInternalServerError, "", 0},
+		{"unavailable", http.StatusServiceUnavailable, "90", 90 * time.Second},
+		{"rate limited", http.StatusTooManyRequests, "5", 5 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+			f, err := fetch.New(fetch.Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			status, err := Send(context.Background(), f, srv.URL, "x", TestPayload(1))
+			var statusErr *fetch.StatusError
+			if !errors.As(err, &statusErr) {
+				t.Fatalf("Send = %d, %v, want a *fetch.StatusError", status, err)
+			}
+			if status != tt.status || statusErr.StatusCode != tt.status {
+				t.Errorf("Send = %d (%d), want %d", status, statusErr.StatusCode, tt.status)
+			}
+			if statusErr.RetryAfter != tt.wantRetry {
+				t.Errorf("RetryAfter = %s, want %s", statusErr.RetryAfter, tt.wantRetry)
+			}
+		})
+	}
+
+	f, err := fetch.New(fetch.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	if status, err := Send(context.Background(), f, srv.URL, "x", TestPayload(1)); err == nil || status != 0 {
+		t.Errorf("Send to a closed server = %d, %v, want 0 and an error", status, err)
+	}
+}
+
+func TestSendDoesNotFollowRedirects(t *testing.T) {
+	followed := false
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hook", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/elsewhere", http.StatusTemporaryRedirect)
+	})
+	mux.HandleFunc("/elsewhere", func(w http.ResponseWriter, r *http.Request) {
+		followed = true
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	f, err := fetch.New(fetch.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err := Send(context.Background(), f, srv.URL+"/hook", "x", TestPayload(1))
+	var statusErr *fetch.StatusError
+	if !errors.As(err, &statusErr) || status != http.StatusTemporaryRedirect {
+		t.Errorf("Send = %d, %v, want 307 and a *fetch.StatusError", status, err)
+	}
+	if followed {
+		t.Error("Send followed the redirect")
+	}
+}
@@ -1,14 +1,18 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"database/sql"
 
 	"github.com/UUest/gator/internal/commands"
 	"github.com/UUest/gator/internal/config"
 	"github.com/UUest/gator/internal/database"
+	"github.com/UUest/gator/internal/fetch"
 	_ "github.com/lib/pq"
 )
 
@@ -25,10 +29,41 @@ func main() {
 	}
 	defer db.Close()
 	dbQueries := database.New(db)
+	fetchOpts, err := cfg.FetchOptions()
+	if err != nil {
+		fmt.Println("Error reading config:", err)
+		os.Exit(1)
+	}
+	fetcher, err := fetch.New(fetchOpts)
+	if err != nil {
+		fmt.Println("Error reading config:", err)
+		os.Exit(1)
+	}
+	logger, err := cfg.Logger(os.Stderr)
+	if err != nil {
+		fmt.Println("Error reading config:", err)
+		os.Exit(1)
+	}
+
+	// The first Ctrl-C or SIGTERM cancels ctx so the command can stop cleanly;
+	// after that the default handling is restored and a second one kills
+	// gator straight away. SIGHUP is left alone here: agg handles it itself to
+	// reload the config, and every other command exits on it as usual, for
+	// example when its terminal is closed.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
 
 	s := commands.State{
-		Config: cfg,
-		DB:     dbQueries,
+		Ctx:     ctx,
+		Config:  cfg,
+		DB:      dbQueries,
+		Conn:    db,
+		Fetcher: fetcher,
+		Logger:  logger,
 	}
 
 	c := commands.Commands{
@@ -36,15 +71,27 @@ func main() {
 	}
 	c.Register("login", commands.HandlerLogin)
 	c.Register("register", commands.HandlerRegister)
-	c.Register("reset", commands.HandlerReset)
+	c.Register("passwd", commands.HandlerPasswd)
+	c.Register("reset", commands.MiddlewareAdmin(commands.HandlerReset))
 	c.Register("users", commands.HandlerGetUsers)
 	c.Register("agg", commands.MiddlewareLoggedIn(commands.HandlerAgg))
 	c.Register("addfeed", commands.MiddlewareLoggedIn(commands.HandlerAddFeed))
-	c.Register("feeds", commands.HandlerGetFeeds)
+	c.Register("feeds", commands.MiddlewareLoggedIn(commands.HandlerGetFeeds))
 	c.Register("follow", commands.MiddlewareLoggedIn(commands.HandlerFollow))
 	c.Register("following", commands.MiddlewareLoggedIn(commands.HandlerFollowing))
 	c.Register("unfollow", commands.MiddlewareLoggedIn(commands.HandlerUnfollow))
 	c.Register("browse", commands.MiddlewareLoggedIn(commands.HandlerGetPosts))
+	c.Register("tui", commands.MiddlewareLoggedIn(commands.HandlerTUI))
+	c.Register("show", commands.MiddlewareLoggedIn(commands.HandlerShowPost))
+	c.Register("feed", commands.MiddlewareLoggedIn(commands.HandlerFeed))
+	c.Register("podcasts", commands.MiddlewareLoggedIn(commands.HandlerPodcasts))
+	c.Register("user", commands.MiddlewareLoggedIn(commands.HandlerUser))
+	c.Register("backup", commands.MiddlewareAdmin(commands.HandlerBackup))
+	c.Register("restore", commands.MiddlewareAdmin(commands.HandlerRestore))
+	c.Register("prune", commands.MiddlewareAdmin(commands.HandlerPrune))
+	c.Register("filter", commands.MiddlewareLoggedIn(commands.HandlerFilter))
+	c.Register("alerts", commands.MiddlewareLoggedIn(commands.HandlerAlerts))
+	c.Register("webhook", commands.MiddlewareLoggedIn(commands.HandlerWebhook))
 
 	input := os.Args
 	switch input[1] {
@@ -58,9 +105,14 @@ func main() {
 			fmt.Println("Usage: gator register <username>")
 			os.Exit(1)
 		}
+	case "passwd":
+		if len(input) < 2 {
+			fmt.Println("Usage: gator passwd [--init <username>]")
+			os.Exit(1)
+		}
 	case "reset":
 		if len(input) < 2 {
-			fmt.Println("Usage: gator reset")
+			fmt.Println("Usage: gator reset [--yes] [--posts-only] [--user <username> [--transfer-to <username> | --delete-feeds]] [--feed <feed_url>]")
 			os.Exit(1)
 		}
 	case "users":
@@ -70,7 +122,7 @@ func main() {
 		}
 	case "agg":
 		if len(input) < 3 {
-			fmt.Println("Usage: gator agg <interval>")
+			fmt.Println("Usage: gator agg [--metrics <addr>] <interval> | gator agg --once [--feed <feed_url>] [min_age]")
 			os.Exit(1)
 		}
 	case "addfeed":
@@ -103,6 +155,61 @@ func main() {
 			fmt.Println("Usage: gator browse")
 			os.Exit(1)
 		}
+	case "tui":
+		if len(input) < 2 {
+			fmt.Println("Usage: gator tui")
+			os.Exit(1)
+		}
+	case "show":
+		if len(input) < 3 {
+			fmt.Println("Usage: gator show <post_id>")
+			os.Exit(1)
+		}
+	case "feed":
+		if len(input) < 3 {
+			fmt.Println("Usage: gator feed <subcommand> [args...]")
+			os.Exit(1)
+		}
+	case "podcasts":
+		if len(input) < 2 {
+			fmt.Println("Usage: gator podcasts [list|download|played] [args...]")
+			os.Exit(1)
+		}
+	case "user":
+		if len(input) < 3 {
+			fmt.Println("Usage: gator user <subcommand> [args...]")
+			os.Exit(1)
+		}
+	case "backup":
+		if len(input) < 3 {
+			fmt.Println("Usage: gator backup <file>")
+			os.Exit(1)
+		}
+	case "restore":
+		if len(input) < 3 {
+			fmt.Println("Usage: gator restore <file>")
+			os.Exit(1)
+		}
+	case "prune":
+		if len(input) < 2 {
+			fmt.Println("Usage: gator prune [--dry-run]")
+			os.Exit(1)
+		}
+	case "filter":
+		if len(input) < 3 {
+			fmt.Println("Usage: gator filter add|list|remove|test [args...]")
+			os.Exit(1)
+		}
+	case "alerts":
+		if len(input) < 2 {
+			fmt.Println("Usage: gator alerts [--keep] [--search <name>] | gator alerts add|searches|remove|test [args...]")
+			os.Exit(1)
+		}
+	case "webhook":
+		if len(input) < 3 {
+			fmt.Println("Usage: gator webhook add|list|remove|log|retry|test [args...]")
+			os.Exit(1)
+		}
 	default:
 		fmt.Println("Unknown command:", input[1])
 		os.Exit(1)
@@ -0,0 +1,79 @@
+-- name: ListFeedFollows :many
+SELECT *
+FROM feed_follows
+ORDER BY id;
+
+-- name: ListPostsAfter :many
+SELECT *
+FROM posts
+WHERE id > $1
+ORDER BY id
+LIMIT $2;
+
+-- name: ListPostStates :many
+SELECT
+  ps.user_id,
+  p.url AS post_url,
+  ps.read_at,
+  ps.starred,
+  ps.updated_at
+FROM post_states ps
+JOIN posts p ON ps.post_id = p.id
+ORDER BY ps.user_id, p.id;
+
+-- name: ListEnclosures :many
+SELECT
+  p.url AS post_url,
+  e.url,
+  e.mime_type,
+  e.length
+FROM enclosures e
+JOIN posts p ON e.post_id = p.id
+ORDER BY e.id;
+
+-- name: ListPodcastDownloads :many
+SELECT
+  pd.user_id,
+  p.url AS post_url,
+  e.url AS enclosure_url,
+  pd.path,
+  pd.sha256,
+  pd.size,
+  pd.downloaded_at,
+  pd.played_at
+FROM podcast_downloads pd
+JOIN enclosures e ON pd.enclosure_id = e.id
+JOIN posts p ON e.post_id = p.id
+ORDER BY pd.user_id, e.id;
+
+-- name: ListFilterRules :many
+SELECT *
+FROM filter_rules
+ORDER BY id;
+
+-- name: ListSavedSearches :many
+SELECT *
+FROM saved_searches
+ORDER BY id;
+
+-- name: ListSearchMatches :many
+SELECT
+  ss.user_id,
+  ss.name AS search_name,
+  p.url AS post_url,
+  sm.matched_at,
+  sm.seen_at
+FROM search_matches sm
+JOIN saved_searches ss ON sm.search_id = ss.id
+JOIN posts p ON sm.post_id = p.id
+ORDER BY ss.id, sm.post_id;
+
+-- name: ListWebhooks :many
+SELECT *
+FROM webhooks
+ORDER BY id;
+
+-- name: ListPrunedPosts :many
+SELECT *
+FROM pruned_posts
+ORDER BY url;
@@ -0,0 +1,46 @@
+-- name: CreateFeedFetch :exec
+INSERT INTO feed_fetches (
+    feed_id,
+    fetched_at,
+    duration_ms,
+    status_code,
+    bytes,
+    items_seen,
+    new_posts,
+    error
+) VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8
+);
+
+-- name: GetFeedFetches :many
+SELECT *
+FROM feed_fetches
+WHERE feed_id = $1
+ORDER BY fetched_at DESC
+LIMIT $2;
+
+-- name: GetFeedFetchStats :one
+SELECT
+    COUNT(*) AS fetches,
+    COUNT(*) FILTER (WHERE error = '') AS succeeded,
+    COALESCE(AVG(items_seen), 0)::float8 AS avg_items,
+    COALESCE(AVG(new_posts), 0)::float8 AS avg_new_posts,
+    COALESCE(AVG(duration_ms), 0)::float8 AS avg_duration_ms
+FROM feed_fetches
+WHERE feed_id = $1;
+
+-- name: PruneFeedFetches :execrows
+DELETE FROM feed_fetches
+WHERE fetched_at < NOW() - make_interval(days => @days::int);
+
+-- name: MoveFeedFetches :execrows
+UPDATE feed_fetches
+SET feed_id = @to_feed_id
+WHERE feed_id = @from_feed_id;
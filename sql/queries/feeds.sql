@@ -50,22 +50,151 @@ JOIN feeds f ON ff.feed_id = f.id
 JOIN users u ON ff.user_id = u.id
 WHERE ff.user_id = $1;
 
+-- name: IsFollowingFeed :one
+SELECT EXISTS (
+  SELECT 1
+  FROM feed_follows
+  WHERE feed_id = $1 AND user_id = $2
+);
+
 -- name: UnfollowFeed :one
 DELETE FROM feed_follows
 WHERE feed_id = $1 AND user_id = $2
 RETURNING *;
 
--- name: MarkFeedFetched :one
+-- name: GetDueFeeds :many
+SELECT f.*
+FROM feeds f
+JOIN feed_follows ff ON f.id = ff.feed_id
+WHERE ff.user_id = @user_id
+  AND f.retired_at IS NULL
+  AND (f.locked_until IS NULL OR f.locked_until < NOW())
+  AND (f.last_fetched IS NULL OR f.last_fetched < NOW() - make_interval(secs => @min_age_seconds::float8))
+ORDER BY f.last_fetched ASC NULLS FIRST;
+
+-- name: SetFeedReadability :one
 UPDATE feeds
-SET last_fetched = NOW(),
+SET readability = $2,
     updated_at = NOW()
 WHERE id = $1
 RETURNING *;
 
--- name: GetNextFeedToFetch :one
-SELECT f.*
+-- name: DeleteFeed :exec
+DELETE FROM feeds
+WHERE id = $1;
+
+-- name: TransferFeedOwnership :execrows
+UPDATE feeds
+SET user_id = @to_user_id,
+    updated_at = NOW()
+WHERE user_id = @from_user_id;
+
+-- name: RenameFeed :one
+UPDATE feeds
+SET name = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: SetFeedURL :one
+UPDATE feeds
+SET url = $2,
+    redirect_url = NULL,
+    redirect_count = 0,
+    retired_at = NULL,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: MoveFeedPosts :execrows
+UPDATE posts
+SET feed_id = @to_feed_id,
+    updated_at = NOW()
+WHERE feed_id = @from_feed_id;
+
+-- name: MoveFeedFollows :execrows
+INSERT INTO feed_follows (user_id, feed_id)
+SELECT ff.user_id, @to_feed_id::uuid
+FROM feed_follows ff
+WHERE ff.feed_id = @from_feed_id
+ON CONFLICT (user_id, feed_id) DO NOTHING;
+
+-- name: RecordFeedRedirect :one
+UPDATE feeds
+SET redirect_count = CASE WHEN redirect_url = $2 THEN redirect_count + 1 ELSE 1 END,
+    redirect_url = $2
+WHERE id = $1
+RETURNING *;
+
+-- name: ClearFeedRedirect :exec
+UPDATE feeds
+SET redirect_url = NULL,
+    redirect_count = 0
+WHERE id = $1 AND redirect_count > 0;
+
+-- name: RetireFeed :exec
+UPDATE feeds
+SET retired_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: ReviveFeed :exec
+UPDATE feeds
+SET retired_at = NULL,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: ClaimNextFeedToFetch :one
+UPDATE feeds
+SET last_fetched = NOW(),
+    locked_until = NOW() + make_interval(secs => @lease_seconds::float8)
+WHERE id = (
+    SELECT f.id
+    FROM feeds f
+    JOIN feed_follows ff ON f.id = ff.feed_id
+    WHERE ff.user_id = @user_id
+      AND f.retired_at IS NULL
+      AND (f.locked_until IS NULL OR f.locked_until < NOW())
+    ORDER BY f.last_fetched ASC NULLS FIRST
+    LIMIT 1
+    FOR UPDATE OF f SKIP LOCKED
+)
+RETURNING *;
+
+-- name: ClaimFeed :one
+UPDATE feeds
+SET last_fetched = NOW(),
+    locked_until = NOW() + make_interval(secs => @lease_seconds::float8)
+WHERE id = @id
+  AND (locked_until IS NULL OR locked_until < NOW())
+  AND (last_fetched IS NULL OR last_fetched < NOW() - make_interval(secs => @min_age_seconds::float8))
+RETURNING *;
+
+-- name: ReleaseFeed :exec
+UPDATE feeds
+SET locked_until = NULL
+WHERE id = $1;
+
+-- name: DeferFeed :exec
+UPDATE feeds
+SET locked_until = NOW() + make_interval(secs => @delay_seconds::float8)
+WHERE id = @id;
+
+-- name: GetFetchBacklog :one
+SELECT
+    COUNT(*) FILTER (
+        WHERE f.last_fetched IS NULL
+           OR f.last_fetched < NOW() - make_interval(secs => @min_age_seconds::float8)
+    ) AS due_feeds,
+    COALESCE(MAX(EXTRACT(EPOCH FROM NOW() - f.last_fetched)), 0)::float8 AS oldest_fetch_age_seconds
 FROM feeds f
 JOIN feed_follows ff ON f.id = ff.feed_id
-WHERE ff.user_id = $1
-ORDER BY f.last_fetched ASC NULLS FIRST
-LIMIT 1;
+WHERE ff.user_id = @user_id AND f.retired_at IS NULL;
+
+-- name: SetFeedRetention :one
+UPDATE feeds
+SET retention_posts = $2,
+    retention_days = $3,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
@@ -0,0 +1,38 @@
+-- name: CreateFilterRule :one
+INSERT INTO filter_rules (user_id, feed_id, title_pattern, action)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: GetFilterRulesForUser :many
+SELECT
+  fr.*,
+  f.url AS feed_url
+FROM filter_rules fr
+LEFT JOIN feeds f ON fr.feed_id = f.id
+WHERE fr.user_id = $1
+ORDER BY fr.id;
+
+-- name: GetFilterRulesForFeed :many
+SELECT fr.*
+FROM filter_rules fr
+WHERE (fr.feed_id IS NULL OR fr.feed_id = @feed_id)
+  AND EXISTS (
+      SELECT 1
+      FROM feed_follows ff
+      WHERE ff.user_id = fr.user_id AND ff.feed_id = @feed_id
+  )
+ORDER BY fr.id;
+
+-- name: DeleteFilterRule :execrows
+DELETE FROM filter_rules
+WHERE id = $1 AND user_id = $2;
+
+-- name: MoveFeedFilterRules :execrows
+UPDATE filter_rules
+SET feed_id = @to_feed_id
+WHERE feed_id = @from_feed_id;
@@ -0,0 +1,91 @@
+-- name: GetPodcastEpisodes :many
+SELECT
+  e.id,
+  e.url,
+  e.mime_type,
+  e.length,
+  p.title,
+  p.published_at,
+  f.name AS feed_name,
+  pd.path,
+  pd.downloaded_at,
+  pd.played_at
+FROM enclosures e
+JOIN posts p ON e.post_id = p.id
+JOIN feeds f ON p.feed_id = f.id
+JOIN feed_follows ff ON ff.feed_id = f.id
+LEFT JOIN podcast_downloads pd ON pd.enclosure_id = e.id AND pd.user_id = ff.user_id
+WHERE ff.user_id = $1
+  AND (e.mime_type LIKE 'audio/%' OR e.mime_type LIKE 'video/%')
+ORDER BY p.published_at DESC
+LIMIT $2;
+
+-- name: GetPodcastEpisode :one
+SELECT
+  e.id,
+  e.url,
+  e.mime_type,
+  e.length,
+  p.title,
+  p.published_at,
+  f.name AS feed_name,
+  pd.path,
+  pd.downloaded_at,
+  pd.played_at
+FROM enclosures e
+JOIN posts p ON e.post_id = p.id
+JOIN feeds f ON p.feed_id = f.id
+JOIN feed_follows ff ON ff.feed_id = f.id
+LEFT JOIN podcast_downloads pd ON pd.enclosure_id = e.id AND pd.user_id = ff.user_id
+WHERE ff.user_id = $1 AND e.id = $2;
+
+-- name: GetCompletedDownloadForEnclosure :one
+SELECT *
+FROM podcast_downloads
+WHERE enclosure_id = $1 AND downloaded_at IS NOT NULL
+LIMIT 1;
+
+-- name: GetCompletedDownloadBySHA256 :one
+SELECT *
+FROM podcast_downloads
+WHERE sha256 = $1 AND downloaded_at IS NOT NULL
+LIMIT 1;
+
+-- name: SavePodcastDownload :exec
+INSERT INTO podcast_downloads (user_id, enclosure_id, path, sha256, size, downloaded_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    NOW()
+)
+ON CONFLICT (user_id, enclosure_id)
+DO UPDATE SET path = EXCLUDED.path,
+              sha256 = EXCLUDED.sha256,
+              size = EXCLUDED.size,
+              downloaded_at = NOW();
+
+-- name: MarkEpisodePlayed :exec
+INSERT INTO podcast_downloads (user_id, enclosure_id, played_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT (user_id, enclosure_id)
+DO UPDATE SET played_at = NOW();
+
+-- name: GetDownloadPathsForPosts :many
+SELECT DISTINCT pd.path
+FROM podcast_downloads pd
+JOIN enclosures e ON e.id = pd.enclosure_id
+WHERE e.post_id = ANY(@post_ids::int[]) AND pd.path <> '';
+
+-- name: IsDownloadPathInUse :one
+SELECT EXISTS (
+    SELECT 1
+    FROM podcast_downloads
+    WHERE path = $1
+);
@@ -0,0 +1,52 @@
+-- name: GetFollowedFeedsWithUnread :many
+SELECT
+  f.id,
+  f.name,
+  f.url,
+  COUNT(p.id) FILTER (WHERE ps.read_at IS NULL) AS unread
+FROM feeds f
+JOIN feed_follows ff ON f.id = ff.feed_id
+LEFT JOIN posts p ON p.feed_id = f.id
+LEFT JOIN post_states ps ON ps.post_id = p.id AND ps.user_id = ff.user_id
+WHERE ff.user_id = $1
+GROUP BY f.id, f.name, f.url
+ORDER BY f.name;
+
+-- name: GetFeedPostsForUser :many
+SELECT
+  p.*,
+  (ps.read_at IS NOT NULL)::boolean AS read,
+  COALESCE(ps.starred, FALSE)::boolean AS starred
+FROM posts p
+LEFT JOIN post_states ps ON ps.post_id = p.id AND ps.user_id = $1
+WHERE p.feed_id = $2
+ORDER BY p.published_at DESC
+LIMIT $3;
+
+-- name: MarkPostRead :exec
+INSERT INTO post_states (user_id, post_id, read_at, updated_at)
+VALUES (
+    $1,
+    $2,
+    NOW(),
+    NOW()
+)
+ON CONFLICT (user_id, post_id)
+DO UPDATE SET read_at = NOW(), updated_at = NOW();
+
+-- name: MarkPostUnread :exec
+UPDATE post_states
+SET read_at = NULL,
+    updated_at = NOW()
+WHERE user_id = $1 AND post_id = $2;
+
+-- name: SetPostStarred :exec
+INSERT INTO post_states (user_id, post_id, starred, updated_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    NOW()
+)
+ON CONFLICT (user_id, post_id)
+DO UPDATE SET starred = EXCLUDED.starred, updated_at = NOW();
@@ -1,4 +1,4 @@
--- name: CreatePost :exec
+-- name: CreatePost :one
 INSERT INTO posts (
     created_at,
     updated_at,
@@ -6,7 +6,11 @@ INSERT INTO posts (
     url,
     description,
     published_at,
-    feed_id
+    feed_id,
+    content,
+    author,
+    categories,
+    comments_url
 ) VALUES (
     NOW(),
     NOW(),
@@ -14,8 +18,14 @@ INSERT INTO posts (
     $2,
     $3,
     $4,
-    $5
-);
+    $5,
+    $6,
+    $7,
+    $8,
+    $9
+)
+ON CONFLICT (url) DO NOTHING
+RETURNING id;
 
 -- name: GetPostsForUser :many
 SELECT p.*
@@ -24,3 +34,112 @@ JOIN feed_follows ff ON p.feed_id = ff.feed_id
 WHERE ff.user_id = $1
 ORDER BY p.updated_at DESC
 LIMIT $2;
+
+-- name: GetPostForUser :one
+SELECT p.*
+FROM posts p
+JOIN feed_follows ff ON p.feed_id = ff.feed_id
+WHERE p.id = $1 AND ff.user_id = $2;
+
+-- name: DeleteAllPosts :exec
+DELETE FROM posts;
+
+-- name: DeleteFeedPosts :exec
+DELETE FROM posts
+WHERE feed_id = $1;
+
+-- name: GetFeedPostStats :one
+SELECT
+    COUNT(*) AS posts,
+    COUNT(*) FILTER (WHERE published_at > NOW() - INTERVAL '30 days') AS recent_posts,
+    COALESCE(EXTRACT(EPOCH FROM MAX(published_at) - MIN(published_at)), 0)::float8 AS span_seconds
+FROM posts
+WHERE feed_id = $1;
+
+
+-- name: GetPrunablePosts :many
+WITH ranked AS (
+    SELECT
+        p.id,
+        p.feed_id,
+        p.published_at,
+        ROW_NUMBER() OVER (PARTITION BY p.feed_id ORDER BY p.published_at DESC, p.id DESC) AS position,
+        COALESCE(f.retention_posts, @default_posts::int) AS keep_posts,
+        COALESCE(f.retention_days, @default_days::int) AS keep_days
+    FROM posts p
+    JOIN feeds f ON f.id = p.feed_id
+), prunable AS (
+    SELECT r.id
+    FROM ranked r
+    WHERE ((r.keep_posts > 0 AND r.position > r.keep_posts)
+        OR (r.keep_days > 0 AND r.published_at < NOW() - make_interval(days => r.keep_days)))
+      AND NOT EXISTS (
+          SELECT 1 FROM post_states ps
+          WHERE ps.post_id = r.id AND ps.starred
+      )
+      AND NOT (@protect_unread::bool AND EXISTS (
+          SELECT 1 FROM feed_follows ff
+          WHERE ff.feed_id = r.feed_id
+            AND NOT EXISTS (
+                SELECT 1 FROM post_states ps
+                WHERE ps.post_id = r.id AND ps.user_id = ff.user_id AND ps.read_at IS NOT NULL
+            )
+      ))
+)
+SELECT p.id, p.feed_id, f.name AS feed_name
+FROM prunable pr
+JOIN posts p ON p.id = pr.id
+JOIN feeds f ON f.id = p.feed_id
+ORDER BY f.name, f.id, p.id;
+
+-- name: PrunePosts :many
+WITH deleted AS (
+    DELETE FROM posts p
+    WHERE p.id = ANY(@ids::int[])
+      AND NOT EXISTS (
+          SELECT 1 FROM post_states ps
+          WHERE ps.post_id = p.id AND ps.starred
+      )
+    RETURNING p.url, p.feed_id
+), tombstones AS (
+    INSERT INTO pruned_posts (url, feed_id, pruned_at)
+    SELECT url, feed_id, NOW() FROM deleted
+    ON CONFLICT (url) DO UPDATE SET pruned_at = EXCLUDED.pruned_at, last_seen_at = EXCLUDED.pruned_at
+)
+SELECT f.name AS feed_name, COUNT(*) AS posts
+FROM deleted d
+JOIN feeds f ON f.id = d.feed_id
+GROUP BY f.id, f.name
+ORDER BY f.name;
+
+-- name: SeePrunedPost :execrows
+UPDATE pruned_posts
+SET last_seen_at = NOW()
+WHERE url = $1;
+
+-- name: ExpirePrunedPosts :execrows
+DELETE FROM pruned_posts
+WHERE last_seen_at < NOW() - make_interval(days => @days::int);
+
+-- name: MoveFeedPrunedPosts :execrows
+UPDATE pruned_posts
+SET feed_id = @to_feed_id
+WHERE feed_id = @from_feed_id;
+
+-- name: UpdatePostFromFeed :execrows
+UPDATE posts
+SET
+    updated_at = NOW(),
+    title = @title,
+    description = @description,
+    content = CASE WHEN @content::text = '' THEN content ELSE @content::text END,
+    author = @author,
+    categories = @categories::text[],
+    comments_url = @comments_url
+WHERE url = @url
+  AND (title <> @title
+    OR description <> @description
+    OR (@content::text <> '' AND content <> @content::text)
+    OR author <> @author
+    OR categories <> @categories::text[]
+    OR comments_url <> @comments_url);
@@ -0,0 +1,148 @@
+-- name: RestoreUser :execrows
+INSERT INTO users (id, created_at, updated_at, name, password_hash, role)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+ON CONFLICT DO NOTHING;
+
+-- name: RestoreFeed :execrows
+INSERT INTO feeds (id, created_at, updated_at, name, url, user_id, last_fetched, readability, retired_at, retention_posts, retention_days)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9,
+    $10,
+    $11
+)
+ON CONFLICT DO NOTHING;
+
+-- name: RestoreFeedFollow :execrows
+INSERT INTO feed_follows (created_at, updated_at, user_id, feed_id)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4
+)
+ON CONFLICT (user_id, feed_id) DO NOTHING;
+
+-- name: RestorePost :execrows
+INSERT INTO posts (created_at, updated_at, title, url, description, published_at, feed_id, content, author, categories, comments_url)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9,
+    $10,
+    $11
+)
+ON CONFLICT (url) DO NOTHING;
+
+-- name: GetPostIDByURL :one
+SELECT id
+FROM posts
+WHERE url = $1;
+
+-- name: RestorePostState :execrows
+INSERT INTO post_states (user_id, post_id, read_at, starred, updated_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+ON CONFLICT (user_id, post_id) DO NOTHING;
+
+-- name: RestoreEnclosure :execrows
+INSERT INTO enclosures (post_id, url, mime_type, length)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4
+)
+ON CONFLICT (post_id, url) DO NOTHING;
+
+-- name: GetEnclosureID :one
+SELECT id
+FROM enclosures
+WHERE post_id = $1 AND url = $2;
+
+-- name: RestorePodcastDownload :execrows
+INSERT INTO podcast_downloads (user_id, enclosure_id, path, sha256, size, downloaded_at, played_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+)
+ON CONFLICT (user_id, enclosure_id) DO NOTHING;
+
+-- name: RestoreFilterRule :execrows
+INSERT INTO filter_rules (created_at, user_id, feed_id, title_pattern, action)
+SELECT @created_at::timestamp, @user_id::uuid, sqlc.narg('feed_id')::uuid, @title_pattern::text, @action::text
+WHERE NOT EXISTS (
+    SELECT 1
+    FROM filter_rules
+    WHERE user_id = @user_id::uuid
+      AND feed_id IS NOT DISTINCT FROM sqlc.narg('feed_id')::uuid
+      AND title_pattern = @title_pattern::text
+      AND action = @action::text
+);
+
+-- name: RestoreSavedSearch :execrows
+INSERT INTO saved_searches (created_at, user_id, name, query)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4
+)
+ON CONFLICT (user_id, name) DO NOTHING;
+
+-- name: RestoreSearchMatch :execrows
+INSERT INTO search_matches (search_id, post_id, matched_at, seen_at)
+SELECT ss.id, @post_id::int, @matched_at::timestamp, sqlc.narg('seen_at')::timestamp
+FROM saved_searches ss
+WHERE ss.user_id = @user_id AND ss.name = @search_name
+ON CONFLICT (search_id, post_id) DO NOTHING;
+
+-- name: RestoreWebhook :execrows
+INSERT INTO webhooks (created_at, user_id, feed_id, url, secret)
+SELECT @created_at::timestamp, @user_id::uuid, sqlc.narg('feed_id')::uuid, @url::text, @secret::text
+WHERE NOT EXISTS (
+    SELECT 1
+    FROM webhooks
+    WHERE user_id = @user_id::uuid
+      AND feed_id IS NOT DISTINCT FROM sqlc.narg('feed_id')::uuid
+      AND url = @url::text
+);
+
+-- name: RestorePrunedPost :execrows
+INSERT INTO pruned_posts (url, feed_id, pruned_at)
+VALUES (
+    $1,
+    $2,
+    $3
+)
+ON CONFLICT (url) DO NOTHING;
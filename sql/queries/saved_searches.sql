@@ -0,0 +1,82 @@
+-- name: CreateSavedSearch :one
+INSERT INTO saved_searches (user_id, name, query)
+VALUES (
+    $1,
+    $2,
+    $3
+)
+ON CONFLICT (user_id, name) DO NOTHING
+RETURNING *;
+
+-- name: GetSavedSearchesForUser :many
+SELECT
+  ss.*,
+  COUNT(sm.post_id) FILTER (WHERE sm.seen_at IS NULL) AS unseen,
+  COUNT(sm.post_id) AS matches
+FROM saved_searches ss
+LEFT JOIN search_matches sm ON sm.search_id = ss.id
+WHERE ss.user_id = $1
+GROUP BY ss.id
+ORDER BY ss.name;
+
+-- name: GetSavedSearchesForFeed :many
+SELECT ss.*
+FROM saved_searches ss
+WHERE EXISTS (
+    SELECT 1
+    FROM feed_follows ff
+    WHERE ff.user_id = ss.user_id AND ff.feed_id = $1
+)
+ORDER BY ss.id;
+
+-- name: DeleteSavedSearch :execrows
+DELETE FROM saved_searches
+WHERE user_id = $1 AND name = $2;
+
+-- name: CreateSearchMatch :exec
+INSERT INTO search_matches (search_id, post_id)
+VALUES (
+    $1,
+    $2
+)
+ON CONFLICT (search_id, post_id) DO NOTHING;
+
+-- name: GetUnseenSearchMatches :many
+SELECT
+  ss.name AS search_name,
+  sm.matched_at,
+  p.id AS post_id,
+  p.title,
+  p.url,
+  f.name AS feed_name
+FROM search_matches sm
+JOIN saved_searches ss ON sm.search_id = ss.id
+JOIN posts p ON sm.post_id = p.id
+JOIN feeds f ON p.feed_id = f.id
+WHERE ss.user_id = @user_id
+  AND sm.seen_at IS NULL
+  AND (sqlc.narg('search_name')::text IS NULL OR ss.name = sqlc.narg('search_name')::text)
+ORDER BY ss.name, sm.matched_at, p.id;
+
+-- name: TakeUnseenSearchMatches :many
+WITH taken AS (
+    UPDATE search_matches sm
+    SET seen_at = NOW()
+    FROM saved_searches ss
+    WHERE sm.search_id = ss.id
+      AND ss.user_id = @user_id
+      AND sm.seen_at IS NULL
+      AND (sqlc.narg('search_name')::text IS NULL OR ss.name = sqlc.narg('search_name')::text)
+    RETURNING ss.name AS search_name, sm.matched_at, sm.post_id
+)
+SELECT
+  t.search_name,
+  t.matched_at,
+  p.id AS post_id,
+  p.title,
+  p.url,
+  f.name AS feed_name
+FROM taken t
+JOIN posts p ON t.post_id = p.id
+JOIN feeds f ON p.feed_id = f.id
+ORDER BY t.search_name, t.matched_at, p.id;
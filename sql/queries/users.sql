@@ -1,13 +1,20 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, name)
+INSERT INTO users (id, created_at, updated_at, name, password_hash, role)
 VALUES (
     $1,
     $2,
     $3,
-    $4
+    $4,
+    $5,
+    CASE WHEN EXISTS (SELECT 1 FROM users) THEN 'member' ELSE 'admin' END
 )
 RETURNING *;
 
+-- name: LockUserCreation :exec
+-- Held until the transaction ends, so concurrent registrations cannot both
+-- see an empty users table and both become admin.
+SELECT pg_advisory_xact_lock(hashtext('gator.create_user'));
+
 -- name: GetUser :one
 SELECT *
 FROM users
@@ -24,3 +31,47 @@ DELETE FROM users;
 -- name: GetUsers :many
 SELECT *
 FROM users;
+
+-- name: SetUserPassword :exec
+UPDATE users
+SET password_hash = $2,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: SetInitialUserPassword :execrows
+UPDATE users
+SET password_hash = $2,
+    updated_at = NOW()
+WHERE id = $1 AND password_hash = '';
+
+-- name: SetUserRole :exec
+UPDATE users
+SET role = $2,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: CountAdmins :one
+SELECT COUNT(*)
+FROM users
+WHERE role = 'admin';
+
+-- name: DeleteUser :exec
+DELETE FROM users
+WHERE id = $1;
+
+-- name: RenameUser :exec
+UPDATE users
+SET name = $2,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetUserStats :one
+SELECT
+  (SELECT COUNT(*) FROM feeds f WHERE f.user_id = $1) AS feeds_added,
+  (SELECT COUNT(*) FROM feed_follows ff WHERE ff.user_id = $1) AS feeds_followed,
+  (SELECT COUNT(*) FROM post_states ps WHERE ps.user_id = $1 AND ps.read_at IS NOT NULL) AS posts_read,
+  (SELECT COUNT(*) FROM post_states ps WHERE ps.user_id = $1 AND ps.starred) AS posts_starred,
+  (SELECT COUNT(DISTINCT ff.user_id)
+   FROM feed_follows ff
+   JOIN feeds f ON ff.feed_id = f.id
+   WHERE f.user_id = $1 AND ff.user_id <> $1) AS other_followers;
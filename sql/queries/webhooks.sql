@@ -0,0 +1,116 @@
+-- name: CreateWebhook :one
+INSERT INTO webhooks (user_id, feed_id, url, secret)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: GetWebhooksForUser :many
+SELECT
+  w.*,
+  f.url AS feed_url,
+  COUNT(d.id) FILTER (WHERE d.status = 'pending') AS pending,
+  COUNT(d.id) FILTER (WHERE d.status = 'delivered') AS delivered,
+  COUNT(d.id) FILTER (WHERE d.status = 'failed') AS failed
+FROM webhooks w
+LEFT JOIN feeds f ON w.feed_id = f.id
+LEFT JOIN webhook_deliveries d ON d.webhook_id = w.id
+WHERE w.user_id = $1
+GROUP BY w.id, f.url
+ORDER BY w.id;
+
+-- name: DeleteWebhook :execrows
+DELETE FROM webhooks
+WHERE id = $1 AND user_id = $2;
+
+-- name: CreateWebhookDeliveries :execrows
+INSERT INTO webhook_deliveries (webhook_id, post_id, next_attempt_at)
+SELECT w.id, @post_id::int, NOW()
+FROM webhooks w
+WHERE (w.feed_id IS NULL OR w.feed_id = @feed_id)
+  AND EXISTS (
+      SELECT 1
+      FROM feed_follows ff
+      WHERE ff.user_id = w.user_id AND ff.feed_id = @feed_id
+  )
+ON CONFLICT (webhook_id, post_id) DO NOTHING;
+
+-- name: ClaimWebhookDeliveries :many
+UPDATE webhook_deliveries d
+SET next_attempt_at = NOW() + make_interval(secs => @lease_seconds::float8)
+FROM webhooks w, posts p, feeds f
+WHERE d.id IN (
+    SELECT id
+    FROM webhook_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= NOW()
+    ORDER BY next_attempt_at
+    LIMIT @max_deliveries
+    FOR UPDATE SKIP LOCKED
+)
+  AND w.id = d.webhook_id
+  AND p.id = d.post_id
+  AND f.id = p.feed_id
+RETURNING
+  d.id,
+  d.attempts,
+  w.id AS webhook_id,
+  w.url AS webhook_url,
+  w.secret,
+  p.id AS post_id,
+  p.title,
+  p.url,
+  p.description,
+  p.author,
+  p.published_at,
+  f.id AS feed_id,
+  f.name AS feed_name,
+  f.url AS feed_url;
+
+-- name: RecordWebhookAttempt :exec
+UPDATE webhook_deliveries
+SET attempts = attempts + 1,
+    last_attempt_at = NOW(),
+    status = @status::text,
+    next_attempt_at = CASE
+        WHEN @status::text = 'pending' THEN NOW() + make_interval(secs => @retry_after_seconds::float8)
+    END,
+    status_code = sqlc.narg('status_code'),
+    error = @error
+WHERE id = @id;
+
+-- name: GetWebhookDeliveries :many
+SELECT
+  d.id,
+  d.created_at,
+  d.status,
+  d.attempts,
+  d.next_attempt_at,
+  d.last_attempt_at,
+  d.status_code,
+  d.error,
+  p.title AS post_title
+FROM webhook_deliveries d
+JOIN posts p ON d.post_id = p.id
+WHERE d.webhook_id = $1
+ORDER BY d.created_at DESC, d.id DESC
+LIMIT $2;
+
+-- name: RetryWebhookDeliveries :execrows
+UPDATE webhook_deliveries
+SET status = 'pending',
+    attempts = 0,
+    next_attempt_at = NOW()
+WHERE webhook_id = $1 AND status = 'failed';
+
+-- name: PruneWebhookDeliveries :execrows
+DELETE FROM webhook_deliveries
+WHERE status <> 'pending'
+  AND last_attempt_at < NOW() - make_interval(days => @days::int);
+
+-- name: MoveFeedWebhooks :execrows
+UPDATE webhooks
+SET feed_id = @to_feed_id
+WHERE feed_id = @from_feed_id;
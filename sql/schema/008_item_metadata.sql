@@ -0,0 +1,23 @@
+-- +goose Up
+ALTER TABLE posts
+ADD COLUMN author TEXT NOT NULL DEFAULT '',
+ADD COLUMN categories TEXT[] NOT NULL DEFAULT '{}',
+ADD COLUMN comments_url TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE enclosures (
+    id SERIAL PRIMARY KEY,
+    post_id INTEGER NOT NULL,
+    url TEXT NOT NULL,
+    mime_type TEXT NOT NULL,
+    length BIGINT NOT NULL DEFAULT 0,
+    FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE,
+    UNIQUE (post_id, url)
+);
+
+-- +goose Down
+DROP TABLE enclosures;
+
+ALTER TABLE posts
+DROP COLUMN comments_url,
+DROP COLUMN categories,
+DROP COLUMN author;
@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE podcast_downloads (
+    user_id UUID NOT NULL,
+    enclosure_id INTEGER NOT NULL,
+    path TEXT NOT NULL DEFAULT '',
+    sha256 TEXT NOT NULL DEFAULT '',
+    size BIGINT NOT NULL DEFAULT 0,
+    downloaded_at TIMESTAMP,
+    played_at TIMESTAMP,
+    PRIMARY KEY (user_id, enclosure_id),
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (enclosure_id) REFERENCES enclosures (id) ON DELETE CASCADE
+);
+
+CREATE INDEX podcast_downloads_sha256_idx ON podcast_downloads (sha256);
+
+-- +goose Down
+DROP TABLE podcast_downloads;
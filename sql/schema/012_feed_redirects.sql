@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE feeds
+ADD COLUMN redirect_url TEXT,
+ADD COLUMN redirect_count INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN retired_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE feeds
+DROP COLUMN retired_at,
+DROP COLUMN redirect_count,
+DROP COLUMN redirect_url;
@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE feed_fetches (
+    id BIGSERIAL PRIMARY KEY,
+    feed_id UUID NOT NULL,
+    fetched_at TIMESTAMP NOT NULL,
+    duration_ms INTEGER NOT NULL,
+    status_code INTEGER,
+    bytes BIGINT NOT NULL DEFAULT 0,
+    items_seen INTEGER NOT NULL DEFAULT 0,
+    new_posts INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    FOREIGN KEY (feed_id) REFERENCES feeds (id) ON DELETE CASCADE
+);
+
+CREATE INDEX feed_fetches_feed_id_fetched_at_idx ON feed_fetches (feed_id, fetched_at);
+
+-- +goose Down
+DROP TABLE feed_fetches;
@@ -0,0 +1,18 @@
+-- +goose Up
+ALTER TABLE feeds
+ADD COLUMN retention_posts INTEGER,
+ADD COLUMN retention_days INTEGER;
+
+CREATE TABLE pruned_posts (
+    url TEXT PRIMARY KEY,
+    feed_id UUID NOT NULL,
+    pruned_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (feed_id) REFERENCES feeds (id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE pruned_posts;
+
+ALTER TABLE feeds
+DROP COLUMN retention_posts,
+DROP COLUMN retention_days;
@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE filter_rules (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    user_id UUID NOT NULL,
+    feed_id UUID,
+    title_pattern TEXT NOT NULL,
+    action TEXT NOT NULL CHECK (action IN ('hide', 'highlight', 'mark-read', 'star')),
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (feed_id) REFERENCES feeds (id) ON DELETE CASCADE
+);
+
+CREATE INDEX filter_rules_user_id_idx ON filter_rules (user_id);
+
+-- +goose Down
+DROP TABLE filter_rules;
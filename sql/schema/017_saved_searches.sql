@@ -0,0 +1,26 @@
+-- +goose Up
+CREATE TABLE saved_searches (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    user_id UUID NOT NULL,
+    name TEXT NOT NULL,
+    query TEXT NOT NULL,
+    UNIQUE (user_id, name),
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE search_matches (
+    search_id INTEGER NOT NULL,
+    post_id INTEGER NOT NULL,
+    matched_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    seen_at TIMESTAMP,
+    PRIMARY KEY (search_id, post_id),
+    FOREIGN KEY (search_id) REFERENCES saved_searches (id) ON DELETE CASCADE,
+    FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE
+);
+
+CREATE INDEX search_matches_unseen_idx ON search_matches (search_id) WHERE seen_at IS NULL;
+
+-- +goose Down
+DROP TABLE search_matches;
+DROP TABLE saved_searches;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE pruned_posts
+ADD COLUMN last_seen_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE pruned_posts
+DROP COLUMN last_seen_at;